@@ -82,21 +82,78 @@ function destroyClickedElement(event) {
 }
 
 var formFields = [
+    "k3d_la_baudRate",
+    "k3d_la_printerLinAdvance",
+    "k3d_la_printerESteps",
     "k3d_la_bedX",
     "k3d_la_bedY",
     "k3d_la_firmwareMarlin",
     "k3d_la_firmwareKlipper",
     "k3d_la_firmwareRRF",
+    "k3d_la_firmwareRepetier",
+    "k3d_la_firmwareSmoothieware",
+    "k3d_la_marlinLA",
     "k3d_la_delta",
-    "k3d_la_g29",
+    "k3d_la_bedProbe",
+    "k3d_la_meshName",
     "k3d_la_travelSpeed",
+    "k3d_la_zTravelSpeed",
+    "k3d_la_acceleration",
+    "k3d_la_jerk",
+    "k3d_la_junctionDeviation",
+    "k3d_la_maxVelocity",
+    "k3d_la_growTower",
+    "k3d_la_minLayerTime",
+    "k3d_la_layerTimeMode",
+    "k3d_la_retractLength",
+    "k3d_la_retractSpeed",
+    "k3d_la_unretractSpeed",
+    "k3d_la_retractExtra",
+    "k3d_la_zHop",
+    "k3d_la_zHopMode",
+    "k3d_la_minTravel",
+    "k3d_la_avoidCrossing",
+    "k3d_la_coastDistance",
+    "k3d_la_wipeDistance",
+    "k3d_la_firmwareRetraction",
+    "k3d_la_retractionSetup",
+    "k3d_la_extrusionMode",
+    "k3d_la_extrusionAfter",
+    "k3d_la_eReset",
+    "k3d_la_volumetricE",
+    "k3d_la_tool",
+    "k3d_la_multiTool",
+    "k3d_la_numTools",
+    "k3d_la_toolTemperatures",
+    "k3d_la_filamentType",
+    "k3d_la_filamentDiameter",
+    "k3d_la_filamentDensity",
+    "k3d_la_filamentPrice",
     "k3d_la_hotendTemperature",
     "k3d_la_bedTemperature",
+    "k3d_la_towerHotendTemperature",
+    "k3d_la_towerBedTemperature",
+    "k3d_la_tempWait",
+    "k3d_la_soakTime",
+    "k3d_la_chamberTemperature",
+    "k3d_la_chamberWait",
+    "k3d_la_chamberSensor",
     "k3d_la_cooling",
+    "k3d_la_firstLayerFan",
+    "k3d_la_fanRampLayers",
+    "k3d_la_partFan",
+    "k3d_la_auxFan",
+    "k3d_la_auxFanSpeed",
+    "k3d_la_fanOff",
     "k3d_la_flow",
+    "k3d_la_flowInE",
+    "k3d_la_extrusionModel",
+    "k3d_la_maxFlow",
+    "k3d_la_flowLimit",
     "k3d_la_firstLayerLineWidth",
     "k3d_la_firstLayerSpeed",
     "k3d_la_zOffset",
+    "k3d_la_zOffsetMode",
     "k3d_la_numPerimeters",
     "k3d_la_lineWidth",
     "k3d_la_layerHeight",
@@ -104,11 +161,16 @@ var formFields = [
     "k3d_la_slowPrintSpeed",
     "k3d_la_initKFactor",
     "k3d_la_endKFactor",
+    "k3d_la_kSchedule",
+    "k3d_la_kList",
     "k3d_la_segmentHeight",
+    "k3d_la_continuousK",
     "k3d_la_numSegments",
 	"k3d_la_startGcode",
 	"k3d_la_endGcode",
-	"k3d_la_smoothTime"
+	"k3d_la_smoothTime",
+	"k3d_la_restoreMode",
+	"k3d_la_normalKFactor"
 ];
 var segmentFields = [
     "k3d_la_initKFactor",
@@ -121,12 +183,72 @@ var segmentKeys = [
 	"num_segments"
 ];
 
+// commands, that make printer report its firmware and settings. Klipper answers only through a forwarded virtual port, Web Serial can't reach its host
+var printerQueries = ['M115', 'M503', 'M900', 'M211', 'M205', 'M572 D0', 'SET_PRESSURE_ADVANCE'];
+
+function sleep(ms) {
+	return new Promise(resolve => setTimeout(resolve, ms));
+}
+
+async function readPrinter() {
+	var description = document.getElementById('table.printer.description');
+	if (!('serial' in navigator)) {
+		description.innerHTML = window.lang.getString('table.printer.description') + '<br><span class="inline-error">' + window.lang.getString('printer.info.unsupported') + '</span>';
+		return;
+	}
+	
+	var port = null;
+	var reader = null;
+	var answers = '';
+	try {
+		port = await navigator.serial.requestPort();
+		await port.open({ baudRate: parseInt(document.getElementById('k3d_la_baudRate').value) });
+		description.innerHTML = window.lang.getString('table.printer.description') + '<br>' + window.lang.getString('printer.info.reading');
+		
+		reader = port.readable.getReader();
+		var decoder = new TextDecoder();
+		var reading = (async () => {
+			while (true) {
+				const { value, done } = await reader.read();
+				if (done) {
+					break;
+				}
+				answers += decoder.decode(value);
+			}
+		})().catch(() => {});
+		
+		// most printers reboot on connection
+		await sleep(3000);
+		var writer = port.writable.getWriter();
+		for (var query of printerQueries) {
+			var okCount = (answers.match(/^ok/gm) || []).length;
+			await writer.write(encoder.encode(query + '\n'));
+			for (var t = 0; t < 40 && (answers.match(/^ok/gm) || []).length <= okCount; t++) {
+				await sleep(50);
+			}
+		}
+		writer.releaseLock();
+		await reader.cancel();
+		await reading;
+		reader.releaseLock();
+		await port.close();
+	} catch (err) {
+		description.innerHTML = window.lang.getString('table.printer.description') + '<br><span class="inline-error">' + err + '</span>';
+		if (port != null) {
+			port.close().catch(() => {});
+		}
+		return;
+	}
+	
+	applyPrinterInfo(answers.replaceAll('\r', ''));
+}
+
 var saveForm = function () {
     for (var elementId of formFields) {
         var element = document.getElementById(elementId);
         if (element) {
             var saveValue = element.value;
-            if (elementId == 'k3d_la_delta' || elementId == 'k3d_la_g29') {
+            if (element.type == 'checkbox') {
                 saveValue = element.checked;
             }
             localStorage.setItem(elementId, saveValue);
@@ -143,7 +265,7 @@ function loadForm() {
 
         var element = document.getElementById(elementId);
         if (element) {
-            if (elementId == 'k3d_la_delta' || elementId == 'k3d_la_g29') {
+            if (element.type == 'checkbox') {
                 if (loadValue == 'true') {
                     element.checked = true;
                 } else {
@@ -165,11 +287,17 @@ function initForm() {
 	for (var elementId of formFields) {
         var element = document.getElementById(elementId);
         element.addEventListener('change', function(e) {
-			saveForm();
-			
 			var el = e.target;
 			var id = el.id;
 			
+			if (id == 'k3d_la_marlinLA' || (el.type == 'radio' && el.name == 'k3d_la_firmware')) {
+				setDefaultKRange();
+			}
+			if (id == 'k3d_la_filamentType') {
+				setFilamentDefaults();
+			}
+			saveForm();
+			
 			if (segmentFields.indexOf(id) != -1) {
 				checkSegments();
 			} else {
@@ -208,28 +336,215 @@ function initLang(key) {
 			values['table.bed_size_x.description'] = '[mm] For cartesian printers - maximum X coordinate<br>For delta-printers - <b>bed diameter</b>';
 			values['table.bed_size_y.title'] = 'Bed size Y';
 			values['table.bed_size_y.description'] = '[mm] For cartesian printers - maximum Y coordinate<br>For delta-printers - <b>bed diameter</b>';
+			values['table.printer.title'] = 'Read settings from printer';
+			values['table.printer.description'] = 'Connect the printer via USB and the calibrator will ask it for firmware (M115) and settings (M503, M900, M211, M205, M572, SET_PRESSURE_ADVANCE) and fill in the form. Bed size is taken from the soft endstops (M211), so it is approximate. Works in Chrome based browsers. USB port of a Klipper printer belongs to its host, that has no G-code serial port, so Klipper can be read only when its virtual port (/tmp/printer) is forwarded to the computer, otherwise fill in the form manually. Generation is refused for Marlin built without LIN_ADVANCE';
+			values['printer.read_button'] = 'Read';
+			values['printer.info.unsupported'] = 'This browser doesn\'t support serial connection';
+			values['printer.info.reading'] = 'Reading...';
+			values['printer.info.not_detected'] = 'Printer didn\'t report its firmware. Check the baud rate';
+			values['printer.info.firmware'] = 'Firmware: %s';
+			values['printer.info.bed'] = 'Bed (approximately, by soft endstops, check it): %s x %s mm';
+			values['printer.info.extruders'] = 'Extruders: %d';
+			values['printer.info.k_factor'] = 'Current K: %s';
+			values['printer.info.e_steps'] = 'E steps: %s steps/mm';
+			values['printer.info.no_linear_advance'] = 'Firmware is built without LIN_ADVANCE';
 			values['table.firmware.title'] = 'Firmware';
-			values['table.firmware.description'] = 'Firmware installed on your printer. If you don\'t know, then it\'s probably Marlin';
+			values['table.firmware.description'] = 'Firmware installed on your printer. If you don\'t know, then it\'s probably Marlin. For Repetier the linear advance L is calibrated (M233 Y), for Smoothieware - pressure advance (M572 S)';
+			values['table.marlin_la.title'] = 'Linear Advance version (Marlin)';
+			values['table.marlin_la.description'] = 'Marlin only. LA 1.0 (Marlin 1.1.x) uses K in tens to hundreds, LA 1.5 (Marlin 2.x) - from 0 to 2. The M900 L option calibrates the second K slot (EXTRA_LIN_ADVANCE_K in Marlin 2.1) leaving the main one untouched';
 			values['table.delta.title'] = 'Origin at the center of the bed';
 			values['table.delta.description'] = 'Must be disabled for cartesian printers, enabled for deltas';
 			values['table.bed_probe.title'] = 'Bed auto-calibration';
-			values['table.bed_probe.description'] = 'Enables bed auto-calibration before printing (G29)? If you don\'t have bed probe, then leave it off.';
+			values['table.bed_probe.description'] = 'How to get the bed heightmap before printing ($G29 placeholder). If you don\'t have bed probe, then leave it "None". "Print area" probes only under the towers, purge lines are shortened to the width of the towers: G29 L R F B in Marlin, BED_MESH_CALIBRATE MESH_MIN/MESH_MAX in Klipper, M557 + G29 S0 in RRF (the grid set by M557 stays changed until config.g is run again, e.g. after restart). M555 - for Prusa printers. "Load mesh" uses the saved heightmap without probing';
+			values['table.bed_probe.none'] = 'None';
+			values['table.bed_probe.full'] = 'Whole bed';
+			values['table.bed_probe.area'] = 'Print area';
+			values['table.bed_probe.prusa'] = 'Print area (M555)';
+			values['table.bed_probe.load'] = 'Load mesh';
+			values['table.mesh_name.title'] = 'Heightmap name';
+			values['table.mesh_name.description'] = 'Which saved heightmap to load: profile in Klipper (BED_MESH_PROFILE LOAD), slot in Marlin (M420 S1 L), file in RRF (G29 S1 P). If empty, the default one is loaded';
 			values['table.travel_speed.title'] = 'Travel speed';
 			values['table.travel_speed.description'] = '[mm/s] The speed at which movements will occur without extrusion';
+			values['table.z_travel_speed.title'] = 'Z travel speed';
+			values['table.z_travel_speed.description'] = '[mm/s] Speed of raising and lowering along the Z axis. Travels with height change are split into Z and XY moves';
+			values['table.acceleration.title'] = 'Acceleration';
+			values['table.acceleration.description'] = '[mm/s²] Print and travel acceleration during the test (M204, SET_VELOCITY_LIMIT ACCEL). Calibration result depends heavily on acceleration, so it is better to set the one you print with. If empty, the acceleration from printer settings is used';
+			values['table.jerk.title'] = 'Jerk / SCV';
+			values['table.jerk.description'] = '[mm/s] X and Y jerk (M205 X Y in Marlin, M566 in RRF) or square corner velocity in Klipper. If empty, it is not changed';
+			values['table.junction_deviation.title'] = 'Junction deviation';
+			values['table.junction_deviation.description'] = '[mm] Junction deviation for Marlin (M205 J) and Smoothieware (M205 X). If empty, it is not changed';
+			values['table.retract_length.title'] = 'Retraction length';
+			values['table.retract_length.description'] = '[mm] Length of filament retraction before travels';
+			values['table.retract_speed.title'] = 'Retraction speed';
+			values['table.retract_speed.description'] = '[mm/s] Speed of filament retraction';
+			values['table.unretract_speed.title'] = 'Deretraction speed';
+			values['table.unretract_speed.description'] = '[mm/s] Speed of filament return after travel';
+			values['table.retract_extra.title'] = 'Extra restart length';
+			values['table.retract_extra.description'] = '[mm] How much filament to add on return after travel. Can be negative';
+			values['table.z_hop.title'] = 'Z-hop';
+			values['table.z_hop.description'] = '[mm] Nozzle lift on retracted travels. 0 - no lift';
+			values['table.z_hop_mode.title'] = 'Z-hop type';
+			values['table.z_hop_mode.description'] = 'Normal - vertical lift, ramp - lift during the first half of travel, spiral - lift along a circle (G2, Klipper needs the [gcode_arcs] section)';
+			values['z_hop_mode.normal'] = 'Normal';
+			values['z_hop_mode.ramp'] = 'Ramp';
+			values['z_hop_mode.spiral'] = 'Spiral';
+			values['table.min_travel.title'] = 'Minimum travel for retraction';
+			values['table.min_travel.description'] = '[mm] Travels shorter than this distance are done without retraction and lift';
+			values['error.retract_length.format'] = 'Retraction length - format error';
+			values['error.retract_length.small_or_big'] = 'Wrong retraction length (less than 0 or greater than 10 mm)';
+			values['error.retract_speed.format'] = 'Retraction speed - format error';
+			values['error.retract_speed.small_or_big'] = 'Wrong retraction speed (less than 1 or greater than 150 mm/s)';
+			values['error.unretract_speed.format'] = 'Deretraction speed - format error';
+			values['error.unretract_speed.small_or_big'] = 'Wrong deretraction speed (less than 1 or greater than 150 mm/s)';
+			values['error.retract_extra.format'] = 'Extra restart length - format error';
+			values['error.retract_extra.small_or_big'] = 'Wrong extra restart length (less than -1 or greater than 2 mm)';
+			values['error.z_hop.format'] = 'Z-hop - format error';
+			values['error.z_hop.small_or_big'] = 'Wrong Z-hop (less than 0 or greater than 5 mm)';
+			values['error.min_travel.format'] = 'Minimum travel - format error';
+			values['error.min_travel.small_or_big'] = 'Wrong minimum travel (less than 0 or greater than 50 mm)';
+			values['table.avoid_crossing.title'] = 'Avoid crossing walls';
+			values['table.avoid_crossing.description'] = 'Travels go around printed purge lines, rafts and towers. Z-hop is done only when they can\'t be avoided';
+			values['generator.travel_distance'] = 'Travel: %s m';
+			values['table.coast_distance.title'] = 'Coasting';
+			values['table.coast_distance.description'] = '[mm] Extrusion of every perimeter stops this distance before the seam, the rest is passed without feeding. 0 - no coasting';
+			values['table.wipe_distance.title'] = 'Wipe';
+			values['table.wipe_distance.description'] = '[mm] At the end of the layer the nozzle moves back along the last perimeter by this distance while retracting. 0 - no wipe. Not available with firmware retraction';
+			values['warning.wipe_distance.firmware_retraction'] = 'Firmware retraction can\'t be combined with movement, wipe is switched off';
+			values['error.coast_distance.format'] = 'Coasting - format error';
+			values['error.coast_distance.small_or_big'] = 'Wrong coasting distance (less than 0 or greater than 5 mm)';
+			values['error.wipe_distance.format'] = 'Wipe - format error';
+			values['error.wipe_distance.small_or_big'] = 'Wrong wipe distance (less than 0 or greater than 10 mm)';
+			values['table.firmware_retraction.title'] = 'Firmware retraction';
+			values['table.firmware_retraction.description'] = 'Use G10/G11 instead of E moves. Marlin needs FWRETRACT, Klipper - the [firmware_retraction] section. Without the setup below retraction settings of the firmware are used';
+			values['table.retraction_setup.title'] = 'Set up firmware retraction';
+			values['table.retraction_setup.description'] = 'Firmware retraction only. Set the calibration retraction length and speed with M207/M208 (SET_RETRACTION in Klipper) at the start of printing';
+			values['table.extrusion_mode.title'] = 'Extrusion mode';
+			values['table.extrusion_mode.description'] = 'Extruder coordinates mode of the file. It is set explicitly after the start G-code';
+			values['extrusion_mode.absolute'] = 'Absolute (M82)';
+			values['extrusion_mode.relative'] = 'Relative (M83)';
+			values['table.extrusion_after.title'] = 'Extrusion mode after printing';
+			values['table.extrusion_after.description'] = 'Extrusion mode set before the end G-code, if it or your macros expect a certain mode';
+			values['extrusion_after.none'] = 'Don\'t change';
+			values['extrusion_after.absolute'] = 'Absolute (M82)';
+			values['extrusion_after.relative'] = 'Relative (M83)';
+			values['table.e_reset.title'] = 'E position reset';
+			values['table.e_reset.description'] = 'Absolute extrusion only. Reset extruder position with G92 E0, so on tall towers it doesn\'t grow to values where precision is lost';
+			values['e_reset.none'] = 'Never';
+			values['e_reset.layer'] = 'Every layer';
+			values['e_reset.segment'] = 'Every segment';
+			values['table.volumetric_e.title'] = 'Volumetric extrusion';
+			values['table.volumetric_e.description'] = 'E values in cubic millimeters. It is enabled by M200 D with filament diameter and disabled (M200 D0) after printing. Klipper doesn\'t support volumetric extrusion';
+			values['table.filament_type.title'] = 'Filament type';
+			values['table.filament_type.description'] = 'Selecting a type puts its typical density, temperatures and K range into the form';
+			values['filament_type.custom'] = 'Other';
+			values['table.filament_diameter.title'] = 'Filament diameter';
+			values['table.filament_diameter.description'] = '[mm] Usually 1.75 or 2.85';
+			values['table.filament_density.title'] = 'Filament density';
+			values['table.filament_density.description'] = '[g/cm³] Used to estimate weight of filament for the calibration';
+			values['table.filament_price.title'] = 'Filament price';
+			values['table.filament_price.description'] = 'Price per kilogram to estimate cost of the calibration. Can be left empty';
+			values['generator.filament_usage'] = 'Filament: %s m, %s g';
+			values['generator.filament_cost'] = ', cost %s';
+			values['error.filament_diameter.format'] = 'Filament diameter - format error';
+			values['error.filament_diameter.small_or_big'] = 'Wrong filament diameter (less than 1 or greater than 3.5 mm)';
+			values['error.filament_density.format'] = 'Filament density - format error';
+			values['error.filament_density.small_or_big'] = 'Wrong filament density (less than 0.5 or greater than 5 g/cm³)';
+			values['error.filament_price.format'] = 'Filament price - format error';
+			values['error.filament_price.negative'] = 'Filament price can\'t be negative';
+			values['table.max_velocity.title'] = 'Max velocity';
+			values['table.min_layer_time.title'] = 'Minimum layer time';
+			values['table.min_layer_time.description'] = '[s] A small tower layer is printed in a few seconds and doesn\'t have time to cool down, especially PETG and TPU. Tower deformations are easily mistaken for LA artifacts. If empty, it is not checked';
+			values['table.layer_time_mode.title'] = 'Layer cooling method';
+			values['table.layer_time_mode.description'] = 'Slow down - fast and slow speeds are reduced in the same ratio. Park and dwell - the nozzle moves behind the tower and waits (G4). Cooling tower - an additional 15x15 mm tower is printed behind the tower';
+			values['layer_time_mode.slow'] = 'Slow down';
+			values['layer_time_mode.dwell'] = 'Park and dwell';
+			values['layer_time_mode.tower'] = 'Cooling tower';
+			values['warning.min_layer_time.slowed'] = 'Print speeds are reduced to %d/%d mm/s';
+			values['warning.min_layer_time.cooling_tower'] = 'Even solid cooling tower doesn\'t fill the minimum layer time';
+			values['generator.layer_time'] = 'Layer time: %s s';
+			values['error.min_layer_time.format'] = 'Minimum layer time - format error';
+			values['error.min_layer_time.small_or_big'] = 'Wrong minimum layer time (less than 0 or greater than 120 s)';
+			values['error.min_layer_time.no_room'] = 'There is no room for the cooling tower behind the tower';
+			values['table.grow_tower.title'] = 'Enlarge tower';
+			values['table.grow_tower.description'] = 'Fast sections of the tower are short, and with low acceleration the printer can\'t reach fast speed on them. If enabled, the tower is enlarged so fast speed is reached (as far as the bed allows). Acceleration must be set';
+			values['warning.acceleration.peak_speed'] = 'Fast sections reach only %s of %d mm/s';
+			values['generator.peak_speed'] = 'Peak speed of fast sections: %s mm/s, tower width: %s mm';
+			values['table.max_velocity.description'] = '[mm/s] Maximum X and Y velocity (M203, SET_VELOCITY_LIMIT VELOCITY). If empty, it is not changed. All changed limits are reverted after printing: in Klipper by macros (their text will be in the file header), in RRF 3.3+ with global variables, in other firmwares by loading from EEPROM (M501). M501 also loads K, home offset and all other settings saved in EEPROM, so unsaved changes are lost, and on boards without EEPROM the limits stay changed until restart';
+			values['table.tool.title'] = 'Tool number';
+			values['table.tool.description'] = 'Tool (extruder) to calibrate. 0 - the main extruder';
+			values['table.multi_tool.title'] = 'Tower for each tool';
+			values['table.multi_tool.description'] = 'For IDEX and toolchanger printers. Each tool prints its own purge line and its own tower, towers are placed in a row from left to right starting with T0. The tool number above is not used in this case. Tools are changed with T0, T1... commands, Klipper needs macros for them';
+			values['table.num_tools.title'] = 'Number of tools';
+			values['table.num_tools.description'] = 'How many tools to calibrate when the tower for each tool is enabled (from 2 to 5)';
+			values['table.tool_temps.title'] = 'Tool temperatures';
+			values['table.tool_temps.description'] = '[°C] Hotend temperatures separated by commas, starting with T0 (for example, 210, 240). Tools without their own temperature use the hotend temperature. Used only when several tools are calibrated. Can be left empty';
 			values['table.hotend_temp.title'] = 'Hotend temperature';
 			values['table.hotend_temp.description'] = '[°C] The temperature to which to heat the hotend before printing';
 			values['table.bed_temp.title'] = 'Bed temperature';
 			values['table.bed_temp.description'] = '[°C] The temperature to which the bed must be heated before printing. The bed will heat up until parking and auto-calibration.';
+			values['table.tower_hotend_temp.title'] = 'Tower hotend temperature';
+			values['table.tower_hotend_temp.description'] = '[°C] The temperature the hotend is switched to after the raft (M104/M109). Temperatures of separate tools are shifted by the same value. If empty, it is the same as hotend temperature';
+			values['table.tower_bed_temp.title'] = 'Tower bed temperature';
+			values['table.tower_bed_temp.description'] = '[°C] The temperature the bed is switched to after the raft (M140/M190). If empty, it is the same as bed temperature';
+			values['table.temp_wait.title'] = 'Wait for tower temperatures';
+			values['table.temp_wait.description'] = 'Wait until tower temperatures are reached (M109/M190) before printing the first tower layer';
+			values['table.soak_time.title'] = 'Bed heat soak';
+			values['table.soak_time.description'] = '[min] Dwell after the start G-code, so the bed and chamber are heated evenly. If empty, there is no dwell';
+			values['table.chamber_temp.title'] = 'Chamber temperature';
+			values['table.chamber_temp.description'] = '[°C] Chamber temperature for ABS and ASA in enclosed printers (M141/M191, TEMPERATURE_WAIT in Klipper). If empty, it is not used';
+			values['table.chamber_wait.title'] = 'Wait for chamber';
+			values['table.chamber_wait.description'] = 'Wait until the chamber is heated (M191) or only switch the heater on (M141). Klipper always waits';
+			values['table.chamber_sensor.title'] = 'Klipper chamber sensor';
+			values['table.chamber_sensor.description'] = 'Full name of the chamber temperature sensor section for TEMPERATURE_WAIT';
 			values['table.fan_speed.title'] = 'Fan speed';
-			values['table.fan_speed.description'] = '[%] Fan speed in percent. In order for the temperature of the hot end not to drop sharply when the fan is turned on, the airflow will be turned off on the 1st layer. On layers 2-4, the fan speed will increase in steps to the specified value';
+			values['table.fan_speed.description'] = '[%] Fan speed in percent. In order for the temperature of the hot end not to drop sharply when the fan is turned on, the fan speed grows from the first layer speed during the ramp layers';
+			values['table.first_layer_fan.title'] = 'First layer fan speed';
+			values['table.first_layer_fan.description'] = '[%] Fan speed while printing the raft and purge lines';
+			values['table.fan_ramp_layers.title'] = 'Fan ramp layers';
+			values['table.fan_ramp_layers.description'] = 'Number of layers after the first one during which fan speed grows to the specified value. 0 - at once on the second layer';
+			values['table.part_fan.title'] = 'Part cooling fan';
+			values['table.part_fan.description'] = 'Fan number (M106 P1) or name of [fan_generic] section in Klipper (SET_FAN_SPEED FAN=...). If empty, the default part cooling fan is used (M106)';
+			values['table.aux_fan.title'] = 'Auxiliary fan';
+			values['table.aux_fan.description'] = 'Number or name (for Klipper) of auxiliary or chamber fan. It is switched on before printing. If empty, it is not used';
+			values['table.aux_fan_speed.title'] = 'Auxiliary fan speed';
+			values['table.aux_fan_speed.description'] = '[%] Auxiliary fan speed';
+			values['table.fan_off.title'] = 'Switch fans off at the end';
+			values['table.fan_off.description'] = 'Switch fans off before the end G-code';
 			values['table.flow.title'] = 'Flow';
 			values['table.flow.description'] = '[%] Flow in percents. Needed to compensate for over- or under-extrusion';
+			values['table.flow_in_e.title'] = 'Flow in E values';
+			values['table.flow_in_e.description'] = 'Multiply E values by flow instead of M221. The $FLOW placeholder of the start G-code is replaced with 100 then';
+			values['table.max_flow.title'] = 'Max volumetric flow';
+			values['table.max_flow.description'] = '[mm³/s] How much plastic the hotend can melt. If empty, it is not checked';
+			values['table.flow_limit.title'] = 'When flow is exceeded';
+			values['table.flow_limit.description'] = 'What to do if flow of fast or slow sections or first layer is greater than the maximum. Otherwise the tower shows underextrusion instead of LA';
+			values['flow_limit.warn'] = 'Warn';
+			values['flow_limit.cap'] = 'Reduce speed';
+			values['generator.flows'] = 'Flow, mm³/s: fast %s, slow %s, first layer %s, purge %s';
+			values['warning.flow.fast'] = 'Flow of fast sections is too high: %s mm³/s';
+			values['warning.flow.slow'] = 'Flow of slow sections is too high: %s mm³/s';
+			values['warning.flow.first_layer'] = 'Flow of the first layer is too high: %s mm³/s';
+			values['warning.flow.fast_capped'] = 'Fast print speed is reduced to %d mm/s';
+			values['warning.flow.slow_capped'] = 'Slow print speed is reduced to %d mm/s';
+			values['warning.flow.first_layer_capped'] = 'First layer print speed is reduced to %d mm/s';
+			values['error.max_flow.format'] = 'Max volumetric flow - format error';
+			values['error.max_flow.small_or_big'] = 'Wrong max volumetric flow (less than 1 or greater than 200 mm³/s)';
+			values['table.extrusion_model.title'] = 'Line cross-section model';
+			values['table.extrusion_model.description'] = 'How line volume is calculated. Rectangle - width by layer height. Rounded cross-section, like in PrusaSlicer and SuperSlicer, gives less plastic and is closer to real prints';
+			values['extrusion_model.rectangle'] = 'Rectangle';
+			values['extrusion_model.rounded'] = 'Rounded (PrusaSlicer)';
 			values['table.first_line_width.title'] = 'First layer line width';
 			values['table.first_line_width.description'] = '[mm] The line width at which the raft will be printed under the towers. In general, it is recommended to set 150% of the nozzle diameter';
 			values['table.first_print_speed.title'] = 'First layer print speed';
 			values['table.first_print_speed.description'] = '[mm/s] The speed at which the raft under the towers will be printed';
 			values['table.z_offset.title'] = 'Z-offset';
 			values['table.z_offset.description'] = '[mm] Offset the entire model vertically. It is necessary to compensate for too thin / thick first layer calibration. Leave zero in general.';
+			values['table.z_offset_mode.title'] = 'Z-offset mode';
+			values['table.z_offset_mode.description'] = 'How to shift the model by Z. Firmware offset: SET_GCODE_OFFSET Z_ADJUST in Klipper, M290 in Marlin and RRF. Home offset: SET_GCODE_OFFSET Z_ADJUST with saved G-code state in Klipper, G10 L2 in RRF. Marlin uses M290, because M206 replaces the stored home offset instead of adding to it. Add to coordinates - the offset is added to every Z coordinate. The offset is reverted after printing. If firmware doesn\'t support the selected mode, the offset is added to coordinates';
+			values['table.z_offset_mode.firmware'] = 'Firmware offset';
+			values['table.z_offset_mode.home_offset'] = 'Home offset';
+			values['table.z_offset_mode.bake'] = 'Add to coordinates';
+			values['table.z_offset_mode.g92'] = 'G92 (old way)';
 			values['table.num_perimeters.title'] = 'Number of perimeters';
 			values['table.num_perimeters.description'] = 'The number of perimeters for the main body of the calibration model. For near-zero shrinkage filaments (PLA, some composites) 1-2. For high shrinkage filaments (ABS and similar) 2+. For flexes 2-4 depending on their rigidity and the desired height of the tower';
 			values['table.line_width.title'] = 'Line width';
@@ -246,10 +561,26 @@ function initLang(key) {
 			values['table.end_la.description'] = 'To what value of the k-factor to calibrate. Rounded to 3 decimal places after the separator. For direct extruders, 0.2 is usually enough, for bowdens 1.5';
 			values['table.num_segments.title'] = 'Number of segments';
 			values['table.num_segments.description'] = 'The number of tower segments. During the segment, the LA coefficient remains unchanged. Segments are visually separated to simplify model analysis';
+			values['table.restore_mode.title'] = 'Restore LA after the test';
+			values['table.restore_mode.description'] = 'What to do with LA after printing, so the next print doesn\'t run with the last K from the test. Saved value: Klipper uses SAVE_GCODE_STATE/RESTORE_GCODE_STATE and K3D_LA_SAVE_PA/K3D_LA_RESTORE_PA macros (their text will be in the file header), RRF 3.3+ uses global variables. Marlin, Repetier and Smoothieware can\'t read K, so the normal K is set for them. The flow is also reset, fans are switched off by the "Switch fans off at the end" setting';
+			values['table.restore_mode.none'] = 'No';
+			values['table.restore_mode.saved'] = 'Saved value';
+			values['table.restore_mode.normal'] = 'Normal K';
+			values['table.normal_k.title'] = 'Normal K';
+			values['table.normal_k.description'] = 'K value you usually print with. It is set after the test if "Normal K" is selected or firmware can\'t save K';
+			values['table.k_schedule.title'] = 'K schedule';
+			values['table.k_schedule.description'] = 'Linear - equal K steps. Logarithmic - fine steps at low K and coarse at high K. List - K values from the field below. If the initial value is greater than the end one, K decreases from bottom to top';
+			values['k_schedule.linear'] = 'Linear';
+			values['k_schedule.log'] = 'Logarithmic';
+			values['k_schedule.list'] = 'List';
+			values['table.k_list.title'] = 'K list';
+			values['table.k_list.description'] = 'K values of segments from bottom to top separated by commas, decimal separator is a dot. Number of segments is equal to the list length';
+			values['table.continuous_k.title'] = 'Continuous K';
+			values['table.continuous_k.description'] = 'K changes every layer instead of every segment. Measure the height of the best band with calipers and find K in the height table';
 			values['table.segment_height.title'] = 'Segment height';
 			values['table.segment_height.description'] = '[mm] The height of one segment of the tower. For example, if the height of the segment is 3mm, and the number of segments is 10, then the height of the entire tower will be 30mm';
 			values['table.start_gcode.title'] = 'Start G-Code';
-			values['table.start_gcode.description'] = 'The code that is executed before test. Change at your own risk! List of possible placeholders:<br><b>$BEDTEMP</b> - bed temperature<br><b>$HOTTEMP</b> - hotend temperature<br><b>$G29</b> - bed heightmap command<br><b>$FLOW</b> - flow';
+			values['table.start_gcode.description'] = 'The code that is executed before test. Change at your own risk! List of possible placeholders:<br><b>$BEDTEMP</b> - bed temperature<br><b>$HOTTEMP</b> - hotend temperature<br><b>$G29</b> - bed heightmap command<br><b>$FLOW</b> - flow<br><b>$TOWERHOTTEMP</b> - tower hotend temperature<br><b>$TOWERBEDTEMP</b> - tower bed temperature<br><b>$CHAMBERTEMP</b> - chamber temperature<br><b>$SOAKTIME</b> - bed heat soak time in seconds';
 			values['table.end_gcode.title'] = 'End G-Code';
 			values['table.end_gcode.description'] = 'The code that is executed after the test. Change at your own risk!';
 			values['table.smooth_time.title'] = 'LA/PA smooth time';
@@ -258,6 +589,7 @@ function initLang(key) {
 			values['generator.generate_and_download'] = 'Generate and download';		
 			values['generator.generate_button_loading'] = 'Generator loading...';
 			values['generator.segment'] = '; Segment %d: K-Factor: %s\n';
+			values['generator.height_k'] = '; Height %s mm: K-Factor: %s\n';
 			values['generator.reset_to_default'] = 'Reset settings';
 			
 			values['navbar.back'] = ' Back ';
@@ -267,12 +599,55 @@ function initLang(key) {
 			values['error.bed_size_x.small_or_big'] = 'Bed size X is incorrect (less than 100 or greater than 1000 mm)';
 			values['error.bed_size_y.format'] = 'Bed size Y - format error';
 			values['error.bed_size_y.small_or_big'] = 'Bed size Y is incorrect (less than 100 or greater than 1000 mm)';
+			values['error.mesh_name.format'] = 'Heightmap name must not contain spaces, quotes or semicolons';
+			values['error.z_travel_speed.format'] = 'Z travel speed - format error';
+			values['error.z_travel_speed.slow_or_fast'] = 'Wrong Z travel speed (less than 1 or greater than 100 mm/s)';
+			values['error.acceleration.format'] = 'Acceleration - format error';
+			values['error.acceleration.small_or_big'] = 'Wrong acceleration (less than 100 or greater than 50000 mm/s²)';
+			values['error.jerk.format'] = 'Jerk - format error';
+			values['error.jerk.small_or_big'] = 'Wrong jerk (less than 1 or greater than 50 mm/s)';
+			values['error.junction_deviation.format'] = 'Junction deviation - format error';
+			values['error.junction_deviation.small_or_big'] = 'Wrong junction deviation (less than 0.001 or greater than 0.3 mm)';
+			values['error.max_velocity.format'] = 'Max velocity - format error';
+			values['error.max_velocity.small_or_big'] = 'Wrong max velocity (less than 10 or greater than 2000 mm/s)';
+			values['error.tool.format'] = 'Tool number - format error';
+			values['error.tool.small_or_big'] = 'Wrong tool number (less than 0 or greater than 7)';
+			values['error.num_tools.format'] = 'Number of tools - format error';
+			values['error.num_tools.small_or_big'] = 'Wrong number of tools (less than 2 or greater than 5)';
+			values['error.num_tools.not_fit'] = 'Towers of all tools don\'t fit on the bed along X';
+			values['error.tool_temps.format'] = 'Tool temperatures - format error';
+			values['error.tool_temps.too_many'] = 'Too many tool temperatures (more than 8)';
+			values['error.tool_temps.low_or_high'] = 'Tool temperature is wrong (less than 150 or greater than 350 °C)';
+			values['warning.gcode.state_change'] = '%s changes printer settings permanently';
+			values['warning.gcode.relative_extrusion'] = 'M83 in start G-code - calibration switches to absolute extrusion, choose relative extrusion mode to keep it';
+			values['warning.gcode.no_homing'] = 'No homing (G28) in start G-code';
+			values['warning.gcode.no_heat_wait'] = 'Start G-code doesn\'t wait for heating (M109/M190)';
+			values['warning.gcode.unknown_placeholders'] = 'Unknown placeholders: %s';
+			values['warning.gcode.foreign_commands'] = 'Commands not supported by %s: %s';
+			values['warning.gcode.likely_firmware'] = 'G-code looks like it is written for %s';
 			values['error.hotend_temp.format'] = 'Hotend temperature - format error';
 			values['error.hotend_temp.too_low'] = 'Hotend temperature is too low';
 			values['error.hotend_temp.too_high'] = 'Hotend temperature is too high';
 			values['error.bed_temp.format'] = 'Bed temperature - format error: ';
 			values['error.bed_temp.too_high'] = 'Bed temperature is too high';
+			values['error.tower_hotend_temp.format'] = 'Tower hotend temperature - format error';
+			values['error.tower_hotend_temp.low_or_high'] = 'Tower hotend temperature is too low or too high (150-350 °C)';
+			values['error.tower_bed_temp.format'] = 'Tower bed temperature - format error';
+			values['error.tower_bed_temp.too_high'] = 'Tower bed temperature is too high';
+			values['error.soak_time.format'] = 'Bed heat soak - format error';
+			values['error.soak_time.small_or_big'] = 'Wrong bed heat soak time (less than 0 or greater than 60 min)';
+			values['error.chamber_temp.format'] = 'Chamber temperature - format error';
+			values['error.chamber_temp.too_high'] = 'Chamber temperature is too high';
+			values['error.chamber_temp.firmware'] = 'Chamber temperature is not supported by Repetier and Smoothieware';
+			values['error.chamber_temp.no_sensor'] = 'Klipper chamber sensor is not set';
 			values['error.fan_speed.format'] = 'Fan speed - format error';
+			values['error.first_layer_fan.format'] = 'First layer fan speed - format error';
+			values['error.fan_ramp_layers.format'] = 'Fan ramp layers - format error';
+			values['error.fan_ramp_layers.small_or_big'] = 'Wrong number of fan ramp layers (less than 0 or greater than 20)';
+			values['error.part_fan.format'] = 'Part cooling fan - format error (number 0-9, name without spaces for Klipper)';
+			values['error.aux_fan_speed.format'] = 'Auxiliary fan speed - format error';
+			values['error.aux_fan.format'] = 'Auxiliary fan - format error (number 0-9, name without spaces for Klipper)';
+			values['error.aux_fan.same'] = 'Auxiliary fan is the same as part cooling fan';
 			values['error.line_width.format'] = 'Line width - format error';
 			values['error.line_width.small_or_big'] = 'Wrong line width (less than 0.1 or greater than 2.0 mm)';
 			values['error.first_line_width.format'] = 'First layer line width - format error';
@@ -292,6 +667,8 @@ function initLang(key) {
 			values['error.flow.format'] = 'Flow - format error';
 			values['error.flow.low_or_high'] = 'Value error: flow should be from 50 to 150%';
 			values['error.firmware.not_set'] = 'Format error: firmware not set';
+			values['error.volumetric_e.unsupported'] = 'Klipper doesn\'t support volumetric extrusion';
+			values['error.firmware.no_linear_advance'] = 'Printer reported Marlin built without LIN_ADVANCE - there is nothing to calibrate. Enable LIN_ADVANCE in Configuration_adv.h and reflash the printer';
 			values['error.num_perimeters.format'] = 'Number of perimeters - format error';
 			values['error.num_perimeters.small_or_big'] = 'Value error: number of perimeters must be between 1 and 5';
 			values['error.fast_segment_speed.format'] = 'Speed of fast sections - format Error';
@@ -299,9 +676,16 @@ function initLang(key) {
 			values['error.slow_segment_speed.format'] = 'Speed of slow sections - format error';
 			values['error.slow_segment_speed.small_or_big'] = 'The print speed of slow sections is incorrect (less than 10 or more than 1000 mm/s)';
 			values['error.init_la.format'] = 'Initial LA coefficient - format error';
-			values['error.init_la.small_or_big'] = 'The initial value of the LA coefficient is incorrect (less than 0.0 or greater than 2.0)';
+			values['error.init_la.small_or_big'] = 'The initial value of the LA coefficient is incorrect';
 			values['error.end_la.format'] = 'Final LA coefficient - format error';
-			values['error.end_la.small_or_big'] = 'The final value of the LA coefficient is incorrect (less than 0.0 or greater than 2.0)';
+			values['error.end_la.small_or_big'] = 'The final value of the LA coefficient is incorrect';
+			values['error.k_factor.range'] = ' (less than %s or greater than %s for the selected firmware)';
+			values['error.k_list.format'] = 'K list - format error';
+			values['error.k_list.small_or_big'] = 'Wrong length of K list (less than 2 or greater than 100 values)';
+			values['error.k_list.out_of_range'] = 'K list has wrong values';
+			values['error.normal_k.format'] = 'Normal K - format error';
+			values['error.normal_k.small_or_big'] = 'Normal K value is incorrect';
+			values['error.normal_k.not_set'] = 'Normal K is not set, but it is needed to restore LA';
 			values['error.smooth_time.format'] = 'Smooth time - format error';
 			values['error.smooth_time.small_or_big'] = 'Smooth time value is incorrect (leass than 0.005 ir greater than 0.2)';
 			break;
@@ -320,28 +704,215 @@ function initLang(key) {
 			values['table.bed_size_x.description'] = '[мм] Для декартовых принтеров - максимальная координата по оси X<br>Для дельта-принтеров - <b>диаметр стола</b>';
 			values['table.bed_size_y.title'] = 'Размер стола по Y';
 			values['table.bed_size_y.description'] = '[мм] Для декартовых принтеров - максимальная координата по оси Y<br>Для дельта-принтеров - <b>диаметр стола</b>';
+			values['table.printer.title'] = 'Чтение настроек из принтера';
+			values['table.printer.description'] = 'Подключите принтер по USB, и калибратор спросит у него прошивку (M115), настройки (M503, M900, M211, M205, M572, SET_PRESSURE_ADVANCE) и заполнит поля. Размер стола берётся из программных концевиков (M211), поэтому он приблизительный. Работает в браузерах на основе Chrome. USB-порт принтера на Klipper занят хостом, у которого нет последовательного порта для G-кода, поэтому Klipper можно прочитать, только если его виртуальный порт (/tmp/printer) проброшен на компьютер, иначе заполните поля вручную. Для Marlin без LIN_ADVANCE генерация будет запрещена';
+			values['printer.read_button'] = 'Прочитать';
+			values['printer.info.unsupported'] = 'Этот браузер не поддерживает подключение по последовательному порту';
+			values['printer.info.reading'] = 'Чтение...';
+			values['printer.info.not_detected'] = 'Принтер не сообщил прошивку. Проверьте скорость порта';
+			values['printer.info.firmware'] = 'Прошивка: %s';
+			values['printer.info.bed'] = 'Стол (примерно, по программным концевикам, проверьте): %s x %s мм';
+			values['printer.info.extruders'] = 'Экструдеров: %d';
+			values['printer.info.k_factor'] = 'Текущий K: %s';
+			values['printer.info.e_steps'] = 'Шаги E: %s шагов/мм';
+			values['printer.info.no_linear_advance'] = 'Прошивка собрана без LIN_ADVANCE';
 			values['table.firmware.title'] = 'Прошивка';
-			values['table.firmware.description'] = 'Прошивка, установленная на вашем принтере. Если не знаете, то, скорее всего, Marlin';
+			values['table.firmware.description'] = 'Прошивка, установленная на вашем принтере. Если не знаете, то, скорее всего, Marlin. Для Repetier калибруется линейный advance L (M233 Y), для Smoothieware - pressure advance (M572 S)';
+			values['table.marlin_la.title'] = 'Версия Linear Advance (Marlin)';
+			values['table.marlin_la.description'] = 'Только для Marlin. LA 1.0 (Marlin 1.1.x) использует K в десятках и сотнях, LA 1.5 (Marlin 2.x) - от 0 до 2. Вариант M900 L калибрует второй слот K (EXTRA_LIN_ADVANCE_K в Marlin 2.1), не трогая основной';
 			values['table.delta.title'] = 'Начало координат в центре стола';
 			values['table.delta.description'] = 'Для декартовых принтеров должно быть выключено, для дельт включено';
 			values['table.bed_probe.title'] = 'Автокалибровка стола';
-			values['table.bed_probe.description'] = 'Надо ли делать автокалибровку стола перед печатью (G29)? Если у вас нет датчика автокалибровки, то оставляйте выключенным';
+			values['table.bed_probe.description'] = 'Как получить карту высот стола перед печатью (плейсхолдер $G29). Если у вас нет датчика автокалибровки, то оставляйте "Нет". "Область печати" снимает карту только под башенками, линии очистки укорачиваются до их ширины: G29 L R F B в Marlin, BED_MESH_CALIBRATE MESH_MIN/MESH_MAX в Klipper, M557 + G29 S0 в RRF (сетка, заданная M557, остаётся изменённой, пока снова не выполнится config.g, например после перезагрузки). M555 - для принтеров Prusa. "Загрузить карту" использует сохранённую карту без замеров';
+			values['table.bed_probe.none'] = 'Нет';
+			values['table.bed_probe.full'] = 'Весь стол';
+			values['table.bed_probe.area'] = 'Область печати';
+			values['table.bed_probe.prusa'] = 'Область печати (M555)';
+			values['table.bed_probe.load'] = 'Загрузить карту';
+			values['table.mesh_name.title'] = 'Имя карты высот';
+			values['table.mesh_name.description'] = 'Какую сохранённую карту загрузить: профиль в Klipper (BED_MESH_PROFILE LOAD), слот в Marlin (M420 S1 L), файл в RRF (G29 S1 P). Если пусто, загружается карта по умолчанию';
 			values['table.travel_speed.title'] = 'Скорость перемещений';
 			values['table.travel_speed.description'] = '[мм/с] Скорость, с которой будут происходить перемещения без экструдирования';
+			values['table.z_travel_speed.title'] = 'Скорость перемещений по Z';
+			values['table.z_travel_speed.description'] = '[мм/с] Скорость подъёма и опускания по оси Z. Перемещения с изменением высоты разделяются на перемещение по Z и по XY';
+			values['table.acceleration.title'] = 'Ускорение';
+			values['table.acceleration.description'] = '[мм/с²] Ускорение печати и перемещений во время теста (M204, SET_VELOCITY_LIMIT ACCEL). Результат калибровки сильно зависит от ускорения, лучше указать то, с которым вы печатаете. Если пусто, используется ускорение из настроек принтера';
+			values['table.jerk.title'] = 'Рывок / SCV';
+			values['table.jerk.description'] = '[мм/с] Рывок по X и Y (M205 X Y в Marlin, M566 в RRF) или square corner velocity в Klipper. Если пусто, не меняется';
+			values['table.junction_deviation.title'] = 'Junction deviation';
+			values['table.junction_deviation.description'] = '[мм] Junction deviation для Marlin (M205 J) и Smoothieware (M205 X). Если пусто, не меняется';
+			values['table.retract_length.title'] = 'Длина ретракта';
+			values['table.retract_length.description'] = '[мм] Длина отката филамента перед перемещениями';
+			values['table.retract_speed.title'] = 'Скорость ретракта';
+			values['table.retract_speed.description'] = '[мм/с] Скорость отката филамента';
+			values['table.unretract_speed.title'] = 'Скорость возврата';
+			values['table.unretract_speed.description'] = '[мм/с] Скорость возврата филамента после перемещения';
+			values['table.retract_extra.title'] = 'Дополнительная длина возврата';
+			values['table.retract_extra.description'] = '[мм] Сколько филамента добавить при возврате после перемещения. Может быть отрицательной';
+			values['table.z_hop.title'] = 'Подъём по Z';
+			values['table.z_hop.description'] = '[мм] Подъём сопла при перемещениях с ретрактом. 0 - без подъёма';
+			values['table.z_hop_mode.title'] = 'Тип подъёма по Z';
+			values['table.z_hop_mode.description'] = 'Обычный - вертикальный подъём, наклонный - подъём во время первой половины перемещения, спиральный - подъём по окружности (G2, в Klipper нужна секция [gcode_arcs])';
+			values['z_hop_mode.normal'] = 'Обычный';
+			values['z_hop_mode.ramp'] = 'Наклонный';
+			values['z_hop_mode.spiral'] = 'Спиральный';
+			values['table.min_travel.title'] = 'Минимальное перемещение для ретракта';
+			values['table.min_travel.description'] = '[мм] Перемещения короче этого расстояния выполняются без ретракта и подъёма';
+			values['error.retract_length.format'] = 'Длина ретракта - ошибка формата';
+			values['error.retract_length.small_or_big'] = 'Длина ретракта неправильная (меньше 0 или больше 10 мм)';
+			values['error.retract_speed.format'] = 'Скорость ретракта - ошибка формата';
+			values['error.retract_speed.small_or_big'] = 'Скорость ретракта неправильная (меньше 1 или больше 150 мм/с)';
+			values['error.unretract_speed.format'] = 'Скорость возврата - ошибка формата';
+			values['error.unretract_speed.small_or_big'] = 'Скорость возврата неправильная (меньше 1 или больше 150 мм/с)';
+			values['error.retract_extra.format'] = 'Дополнительная длина возврата - ошибка формата';
+			values['error.retract_extra.small_or_big'] = 'Дополнительная длина возврата неправильная (меньше -1 или больше 2 мм)';
+			values['error.z_hop.format'] = 'Подъём по Z - ошибка формата';
+			values['error.z_hop.small_or_big'] = 'Подъём по Z неправильный (меньше 0 или больше 5 мм)';
+			values['error.min_travel.format'] = 'Минимальное перемещение - ошибка формата';
+			values['error.min_travel.small_or_big'] = 'Минимальное перемещение неправильное (меньше 0 или больше 50 мм)';
+			values['table.avoid_crossing.title'] = 'Не пересекать стенки';
+			values['table.avoid_crossing.description'] = 'Перемещения огибают напечатанные линии прочистки, подложки и башенки. Подъём по Z делается только тогда, когда обойти их нельзя';
+			values['generator.travel_distance'] = 'Перемещения: %s м';
+			values['table.coast_distance.title'] = 'Накат';
+			values['table.coast_distance.description'] = '[мм] Экструзия каждого периметра заканчивается на это расстояние раньше шва, остаток проходится без подачи. 0 - без наката';
+			values['table.wipe_distance.title'] = 'Очистка сопла';
+			values['table.wipe_distance.description'] = '[мм] В конце слоя сопло возвращается назад по последнему периметру на это расстояние, одновременно делая ретракт. 0 - без очистки. Недоступно с ретрактом прошивки';
+			values['warning.wipe_distance.firmware_retraction'] = 'Ретракт прошивки нельзя совместить с движением, очистка отключена';
+			values['error.coast_distance.format'] = 'Накат - ошибка формата';
+			values['error.coast_distance.small_or_big'] = 'Накат неправильный (меньше 0 или больше 5 мм)';
+			values['error.wipe_distance.format'] = 'Очистка сопла - ошибка формата';
+			values['error.wipe_distance.small_or_big'] = 'Очистка сопла неправильная (меньше 0 или больше 10 мм)';
+			values['table.firmware_retraction.title'] = 'Ретракт прошивкой';
+			values['table.firmware_retraction.description'] = 'Использовать G10/G11 вместо движений E. В Marlin нужен FWRETRACT, в Klipper - секция [firmware_retraction]. Без настройки ниже будут использоваться параметры ретракта из прошивки';
+			values['table.retraction_setup.title'] = 'Настроить ретракт прошивки';
+			values['table.retraction_setup.description'] = 'Только для ретракта прошивкой. Задать длину и скорость ретракта калибровки командами M207/M208 (SET_RETRACTION в Klipper) в начале печати';
+			values['table.extrusion_mode.title'] = 'Режим экструзии';
+			values['table.extrusion_mode.description'] = 'Режим координат экструдера в файле. Он явно задаётся после стартового G-кода';
+			values['extrusion_mode.absolute'] = 'Абсолютный (M82)';
+			values['extrusion_mode.relative'] = 'Относительный (M83)';
+			values['table.extrusion_after.title'] = 'Режим экструзии после печати';
+			values['table.extrusion_after.description'] = 'Режим экструзии, который будет выставлен перед конечным G-кодом, если он или ваши макросы рассчитывают на определённый режим';
+			values['extrusion_after.none'] = 'Не менять';
+			values['extrusion_after.absolute'] = 'Абсолютный (M82)';
+			values['extrusion_after.relative'] = 'Относительный (M83)';
+			values['table.e_reset.title'] = 'Сброс координаты E';
+			values['table.e_reset.description'] = 'Только для абсолютной экструзии. Командой G92 E0 сбрасывать координату экструдера, чтобы на высоких башенках она не росла до значений, где теряется точность';
+			values['e_reset.none'] = 'Не сбрасывать';
+			values['e_reset.layer'] = 'Каждый слой';
+			values['e_reset.segment'] = 'Каждый сегмент';
+			values['table.volumetric_e.title'] = 'Объёмная экструзия';
+			values['table.volumetric_e.description'] = 'Значения E в кубических миллиметрах. Включается командой M200 D с диаметром филамента и выключается (M200 D0) после печати. Klipper не поддерживает объёмную экструзию';
+			values['table.filament_type.title'] = 'Тип филамента';
+			values['table.filament_type.description'] = 'При выборе типа в форму подставляются типичные плотность, температуры и диапазон K для него';
+			values['filament_type.custom'] = 'Другой';
+			values['table.filament_diameter.title'] = 'Диаметр филамента';
+			values['table.filament_diameter.description'] = '[мм] Обычно 1.75 или 2.85';
+			values['table.filament_density.title'] = 'Плотность филамента';
+			values['table.filament_density.description'] = '[г/см³] Нужна для расчёта веса филамента на калибровку';
+			values['table.filament_price.title'] = 'Цена филамента';
+			values['table.filament_price.description'] = 'Цена за килограмм для расчёта стоимости калибровки. Можно оставить пустым';
+			values['generator.filament_usage'] = 'Филамент: %s м, %s г';
+			values['generator.filament_cost'] = ', стоимость %s';
+			values['error.filament_diameter.format'] = 'Диаметр филамента - ошибка формата';
+			values['error.filament_diameter.small_or_big'] = 'Диаметр филамента неправильный (меньше 1 или больше 3.5 мм)';
+			values['error.filament_density.format'] = 'Плотность филамента - ошибка формата';
+			values['error.filament_density.small_or_big'] = 'Плотность филамента неправильная (меньше 0.5 или больше 5 г/см³)';
+			values['error.filament_price.format'] = 'Цена филамента - ошибка формата';
+			values['error.filament_price.negative'] = 'Цена филамента не может быть отрицательной';
+			values['table.max_velocity.title'] = 'Максимальная скорость';
+			values['table.min_layer_time.title'] = 'Минимальное время слоя';
+			values['table.min_layer_time.description'] = '[с] Маленький слой башенки печатается за несколько секунд и не успевает остыть, особенно PETG и TPU. Деформации башенки легко спутать с работой LA. Если пусто, не проверяется';
+			values['table.layer_time_mode.title'] = 'Способ охлаждения слоя';
+			values['table.layer_time_mode.description'] = 'Замедлить печать - быстрая и медленная скорости уменьшаются в одинаковое число раз. Пауза в стороне - сопло отъезжает за башенку и ждёт (G4). Охлаждающая башенка - за башенкой печатается дополнительная башенка 15x15 мм';
+			values['layer_time_mode.slow'] = 'Замедлить печать';
+			values['layer_time_mode.dwell'] = 'Пауза в стороне';
+			values['layer_time_mode.tower'] = 'Охлаждающая башенка';
+			values['warning.min_layer_time.slowed'] = 'Скорости печати снижены до %d/%d мм/с';
+			values['warning.min_layer_time.cooling_tower'] = 'Даже сплошная охлаждающая башенка не заполняет минимальное время слоя';
+			values['generator.layer_time'] = 'Время слоя: %s с';
+			values['error.min_layer_time.format'] = 'Минимальное время слоя - ошибка формата';
+			values['error.min_layer_time.small_or_big'] = 'Минимальное время слоя неправильное (меньше 0 или больше 120 с)';
+			values['error.min_layer_time.no_room'] = 'За башенкой нет места для охлаждающей башенки';
+			values['table.grow_tower.title'] = 'Увеличить башенку';
+			values['table.grow_tower.description'] = 'Быстрые участки башенки короткие, и при небольшом ускорении принтер не успевает разогнаться до быстрой скорости. Если включено, башенка увеличивается, чтобы быстрая скорость достигалась (насколько позволяет стол). Нужно указать ускорение';
+			values['warning.acceleration.peak_speed'] = 'Быстрые участки разгоняются только до %s из %d мм/с';
+			values['generator.peak_speed'] = 'Пиковая скорость быстрых участков: %s мм/с, ширина башенки: %s мм';
+			values['table.max_velocity.description'] = '[мм/с] Максимальная скорость по X и Y (M203, SET_VELOCITY_LIMIT VELOCITY). Если пусто, не меняется. Все изменённые ограничения возвращаются после печати: в Klipper макросами (их текст будет в заголовке файла), в RRF 3.3+ через глобальные переменные, в остальных прошивках загрузкой из EEPROM (M501). M501 также загружает K, смещение дома и все остальные сохранённые в EEPROM настройки, поэтому несохранённые изменения теряются, а на платах без EEPROM ограничения остаются изменёнными до перезагрузки';
+			values['table.tool.title'] = 'Номер инструмента';
+			values['table.tool.description'] = 'Инструмент (экструдер), для которого проводится калибровка. 0 - основной экструдер';
+			values['table.multi_tool.title'] = 'Башенка для каждого инструмента';
+			values['table.multi_tool.description'] = 'Для IDEX и принтеров со сменой инструмента. Каждый инструмент напечатает свою линию очистки и свою башенку, башенки стоят в ряд слева направо начиная с T0. Номер инструмента выше при этом не используется. Смена инструмента делается командами T0, T1..., в Klipper для них нужны макросы';
+			values['table.num_tools.title'] = 'Количество инструментов';
+			values['table.num_tools.description'] = 'Сколько инструментов калибровать, если включена печать башенки для каждого инструмента (от 2 до 5)';
+			values['table.tool_temps.title'] = 'Температуры инструментов';
+			values['table.tool_temps.description'] = '[°C] Температуры хотэндов через запятую, начиная с T0 (например, 210, 240). Инструменты без своей температуры используют температуру хотэнда. Используется только при калибровке нескольких инструментов. Можно оставить пустым';
 			values['table.hotend_temp.title'] = 'Температура хотэнда';
 			values['table.hotend_temp.description'] = '[°C] До прогрева стола хотэнд будет нагрет до 150 градусов. После полного нагрева стола хотэнд догреется до указанной температуры';
 			values['table.bed_temp.title'] = 'Температура стола';
 			values['table.bed_temp.description'] = '[°C] Температура, до которой нагреть стол перед печатью. Стол будет нагрет до выполнения парковки и автокалибровки стола';
+			values['table.tower_hotend_temp.title'] = 'Температура хотэнда для башенки';
+			values['table.tower_hotend_temp.description'] = '[°C] Температура, на которую хотэнд переключается после подложки (M104/M109). Температуры отдельных инструментов сдвигаются на ту же величину. Если пусто, совпадает с температурой хотэнда';
+			values['table.tower_bed_temp.title'] = 'Температура стола для башенки';
+			values['table.tower_bed_temp.description'] = '[°C] Температура, на которую стол переключается после подложки (M140/M190). Если пусто, совпадает с температурой стола';
+			values['table.temp_wait.title'] = 'Ждать температуры башенки';
+			values['table.temp_wait.description'] = 'Дождаться нагрева до температур башенки (M109/M190) перед печатью первого слоя башенки';
+			values['table.soak_time.title'] = 'Прогрев стола';
+			values['table.soak_time.description'] = '[мин] Пауза после стартового G-кода, чтобы стол и камера прогрелись равномерно. Если пусто, паузы нет';
+			values['table.chamber_temp.title'] = 'Температура камеры';
+			values['table.chamber_temp.description'] = '[°C] Температура камеры для ABS и ASA в закрытых принтерах (M141/M191, в Klipper TEMPERATURE_WAIT). Если пусто, не используется';
+			values['table.chamber_wait.title'] = 'Ждать нагрева камеры';
+			values['table.chamber_wait.description'] = 'Дождаться нагрева камеры (M191) или только включить нагреватель (M141). Klipper всегда ждёт';
+			values['table.chamber_sensor.title'] = 'Датчик камеры Klipper';
+			values['table.chamber_sensor.description'] = 'Полное имя секции датчика температуры камеры для TEMPERATURE_WAIT';
 			values['table.fan_speed.title'] = 'Скорость вентилятора';
-			values['table.fan_speed.description'] = '[%] Обороты вентилятора в процентах. Для того, чтобы температура хотэнда резко не упала при включении вентилятора, на 1 слое обдув будет выключен. На 2-4 слоях скорость вращения вентиляторов будет ступенчато увелиичиваться до указанного значения';
+			values['table.fan_speed.description'] = '[%] Обороты вентилятора в процентах. Для того, чтобы температура хотэнда резко не упала при включении вентилятора, обороты плавно растут от скорости первого слоя в течение слоёв разгона';
+			values['table.first_layer_fan.title'] = 'Вентилятор на первом слое';
+			values['table.first_layer_fan.description'] = '[%] Обороты вентилятора при печати подложки и линий прочистки';
+			values['table.fan_ramp_layers.title'] = 'Слои разгона вентилятора';
+			values['table.fan_ramp_layers.description'] = 'За сколько слоёв после первого обороты вырастут до заданных. 0 - сразу на втором слое';
+			values['table.part_fan.title'] = 'Вентилятор обдува';
+			values['table.part_fan.description'] = 'Номер вентилятора (M106 P1) или имя секции [fan_generic] в Klipper (SET_FAN_SPEED FAN=...). Если пусто, используется основной вентилятор обдува (M106)';
+			values['table.aux_fan.title'] = 'Дополнительный вентилятор';
+			values['table.aux_fan.description'] = 'Номер или имя (для Klipper) дополнительного вентилятора или вентилятора камеры. Включается перед печатью. Если пусто, не используется';
+			values['table.aux_fan_speed.title'] = 'Скорость доп. вентилятора';
+			values['table.aux_fan_speed.description'] = '[%] Обороты дополнительного вентилятора';
+			values['table.fan_off.title'] = 'Выключить вентиляторы в конце';
+			values['table.fan_off.description'] = 'Выключить вентиляторы перед конечным G-кодом';
 			values['table.flow.title'] = 'Поток';
 			values['table.flow.description'] = '[%] Поток в процентах. Нужен для компенсации пере- или недоэкструзии';
+			values['table.flow_in_e.title'] = 'Поток в значениях E';
+			values['table.flow_in_e.description'] = 'Умножить значения E на поток вместо M221. Плейсхолдер $FLOW в стартовом G-коде при этом заменяется на 100';
+			values['table.max_flow.title'] = 'Максимальный объёмный расход';
+			values['table.max_flow.description'] = '[мм³/с] Сколько пластика успевает расплавить хотэнд. Если пусто, не проверяется';
+			values['table.flow_limit.title'] = 'При превышении расхода';
+			values['table.flow_limit.description'] = 'Что делать, если расход на быстрых, медленных участках или первом слое больше максимального. Иначе башенка покажет недоэкструзию вместо работы LA';
+			values['flow_limit.warn'] = 'Предупредить';
+			values['flow_limit.cap'] = 'Снизить скорость';
+			values['generator.flows'] = 'Расход, мм³/с: быстрые участки %s, медленные %s, первый слой %s, прочистка %s';
+			values['warning.flow.fast'] = 'Слишком большой расход на быстрых участках: %s мм³/с';
+			values['warning.flow.slow'] = 'Слишком большой расход на медленных участках: %s мм³/с';
+			values['warning.flow.first_layer'] = 'Слишком большой расход на первом слое: %s мм³/с';
+			values['warning.flow.fast_capped'] = 'Скорость быстрых участков снижена до %d мм/с';
+			values['warning.flow.slow_capped'] = 'Скорость медленных участков снижена до %d мм/с';
+			values['warning.flow.first_layer_capped'] = 'Скорость первого слоя снижена до %d мм/с';
+			values['error.max_flow.format'] = 'Максимальный объёмный расход - ошибка формата';
+			values['error.max_flow.small_or_big'] = 'Максимальный объёмный расход неправильный (меньше 1 или больше 200 мм³/с)';
+			values['table.extrusion_model.title'] = 'Модель сечения линии';
+			values['table.extrusion_model.description'] = 'Как считать объём линии. Прямоугольник - ширина на высоту слоя. Скруглённое сечение, как в PrusaSlicer и SuperSlicer, даёт меньше пластика и ближе к реальной печати';
+			values['extrusion_model.rectangle'] = 'Прямоугольник';
+			values['extrusion_model.rounded'] = 'Скруглённое (PrusaSlicer)';
 			values['table.first_line_width.title'] = 'Ширина линии первого слоя';
 			values['table.first_line_width.description'] = '[мм] Ширина линий, с которой будет напечатана подложка под моделью. В общем случае рекомендуется выставить 150% от диаметра сопла';
 			values['table.first_print_speed.title'] = 'Скорость печати первого слоя';
 			values['table.first_print_speed.description'] = '[мм/с] Скорость, с которой будет напечатана подложка';
 			values['table.z_offset.title'] = 'Z-offset';
 			values['table.z_offset.description'] = '[мм] Смещение всей модели по вертикали. Нужно чтобы компенсировать слишком тонкую/толстую калибровку первого слоя. В общем случае оставьте ноль';
+			values['table.z_offset_mode.title'] = 'Способ применения Z-offset';
+			values['table.z_offset_mode.description'] = 'Как сместить модель по Z. Смещение прошивки: SET_GCODE_OFFSET Z_ADJUST в Klipper, M290 в Marlin и RRF. Смещение дома: SET_GCODE_OFFSET Z_ADJUST с сохранением состояния G-кода в Klipper, G10 L2 в RRF. В Marlin используется M290, потому что M206 заменяет сохранённое смещение дома, а не прибавляет к нему. Прибавить к координатам - смещение добавляется к каждой координате Z. Смещение отменяется после печати. Если прошивка не поддерживает выбранный способ, смещение прибавляется к координатам';
+			values['table.z_offset_mode.firmware'] = 'Смещение прошивки';
+			values['table.z_offset_mode.home_offset'] = 'Смещение дома';
+			values['table.z_offset_mode.bake'] = 'Прибавить к координатам';
+			values['table.z_offset_mode.g92'] = 'G92 (старый способ)';
 			values['table.num_perimeters.title'] = 'Количество периметров';
 			values['table.num_perimeters.description'] = 'Количество периметров для основного тела калибровочной модели. Для филаментов с околонулевой усадкой (PLA, некоторые композиты) 1-2. Для филаментов с сильной усадкой (ABS и подобные) 2+. Для флексов 2-4 в зависимости от их жесткости и желаемой высоты башенки';
 			values['table.line_width.title'] = 'Ширина линии';
@@ -358,10 +929,26 @@ function initLang(key) {
 			values['table.end_la.description'] = 'До какого значения к-фактора проводить калибровку. Округляется до 3 знака после разделителя. Для директ экструдеров обычно хватает 0.2, для боуденов 1.5';
 			values['table.num_segments.title'] = 'Количество сегментов';
 			values['table.num_segments.description'] = 'Количество сегментов башенки. В течение сегмента коэффициент LA остаётся неизменным. Сегменты визуально разделены для упрощения анализа модели';
+			values['table.restore_mode.title'] = 'Восстановление LA после теста';
+			values['table.restore_mode.description'] = 'Что сделать с LA после печати, чтобы следующая печать не шла с последним K из теста. Сохранённое значение: в Klipper используются SAVE_GCODE_STATE/RESTORE_GCODE_STATE и макросы K3D_LA_SAVE_PA/K3D_LA_RESTORE_PA (их текст будет в заголовке файла), в RRF 3.3+ - глобальные переменные. Marlin, Repetier и Smoothieware не умеют читать K, поэтому для них будет выставлен обычный K. Также сбрасывается поток, вентиляторы выключаются настройкой "Выключить вентиляторы в конце"';
+			values['table.restore_mode.none'] = 'Нет';
+			values['table.restore_mode.saved'] = 'Сохранённое значение';
+			values['table.restore_mode.normal'] = 'Обычный K';
+			values['table.normal_k.title'] = 'Обычный K';
+			values['table.normal_k.description'] = 'Значение K, с которым вы печатаете обычно. Выставляется после теста, если выбран "Обычный K" или прошивка не умеет сохранять K';
+			values['table.k_schedule.title'] = 'Распределение K';
+			values['table.k_schedule.description'] = 'Равномерное - одинаковый шаг K. Логарифмическое - мелкий шаг на малых K и крупный на больших. Список - значения K из поля ниже. Если начальное значение больше конечного, K на башенке убывает снизу вверх';
+			values['k_schedule.linear'] = 'Равномерное';
+			values['k_schedule.log'] = 'Логарифмическое';
+			values['k_schedule.list'] = 'Список';
+			values['table.k_list.title'] = 'Список K';
+			values['table.k_list.description'] = 'Значения K сегментов снизу вверх через запятую, дробная часть отделяется точкой. Количество сегментов равно длине списка';
+			values['table.continuous_k.title'] = 'Плавное изменение K';
+			values['table.continuous_k.description'] = 'K меняется на каждом слое, а не на каждом сегменте. Измерьте штангенциркулем высоту лучшей полосы и найдите K в таблице высот';
 			values['table.segment_height.title'] = 'Высота сегмента';
 			values['table.segment_height.description'] = '[мм] Высота одного сегмента башенки. К примеру, если высота сегмента 3мм, а количество сегментов 10, то высота всей башенки будет 30мм';
 			values['table.start_gcode.title'] = 'Начальный G-код';
-			values['table.start_gcode.description'] = 'Код, выполняемый перед печатью теста. Менять на свой страх и риск! Список возможных плейсхолдеров:<br><b>$BEDTEMP</b> - температура стола<br><b>$HOTTEMP</b> - температура хотэнда<br><b>$G29</b> - команда на снятие карты высот стола<br><b>$FLOW</b> - поток';
+			values['table.start_gcode.description'] = 'Код, выполняемый перед печатью теста. Менять на свой страх и риск! Список возможных плейсхолдеров:<br><b>$BEDTEMP</b> - температура стола<br><b>$HOTTEMP</b> - температура хотэнда<br><b>$G29</b> - команда на снятие карты высот стола<br><b>$FLOW</b> - поток<br><b>$TOWERHOTTEMP</b> - температура хотэнда для башенки<br><b>$TOWERBEDTEMP</b> - температура стола для башенки<br><b>$CHAMBERTEMP</b> - температура камеры<br><b>$SOAKTIME</b> - время прогрева стола в секундах';
 			values['table.end_gcode.title'] = 'Конечный G-код';
 			values['table.end_gcode.description'] = 'Код, выполняемый после печати теста. Менять на свой страх и риск!';
 			values['table.smooth_time.title'] = 'Время сглаживания LA/PA'
@@ -369,6 +956,7 @@ function initLang(key) {
 			values['generator.generate_and_download'] = 'Генерировать и скачать';		
 			values['generator.generate_button_loading'] = 'Генератор загружается...';		
 			values['generator.segment'] = '; Сегмент %d: K-Factor: %s\n';
+			values['generator.height_k'] = '; Высота %s мм: K-Factor: %s\n';
 			values['generator.reset_to_default'] = 'Сбросить настройки';
 			
 			values['navbar.back'] = ' Назад ';
@@ -378,12 +966,55 @@ function initLang(key) {
 			values['error.bed_size_x.small_or_big'] = 'Размер стола по X указан неверно (меньше 100 или больше 1000 мм)';
 			values['error.bed_size_y.format'] = 'Размер оси Y - ошибка формата';
 			values['error.bed_size_y.small_or_big'] = 'Размер стола по Y указан неверно (меньше 100 или больше 1000 мм)';
+			values['error.mesh_name.format'] = 'Имя карты высот не должно содержать пробелы, кавычки и точки с запятой';
+			values['error.z_travel_speed.format'] = 'Скорость перемещений по Z - ошибка формата';
+			values['error.z_travel_speed.slow_or_fast'] = 'Скорость перемещений по Z неправильная (меньше 1 или больше 100 мм/с)';
+			values['error.acceleration.format'] = 'Ускорение - ошибка формата';
+			values['error.acceleration.small_or_big'] = 'Ускорение неправильное (меньше 100 или больше 50000 мм/с²)';
+			values['error.jerk.format'] = 'Рывок - ошибка формата';
+			values['error.jerk.small_or_big'] = 'Рывок неправильный (меньше 1 или больше 50 мм/с)';
+			values['error.junction_deviation.format'] = 'Junction deviation - ошибка формата';
+			values['error.junction_deviation.small_or_big'] = 'Junction deviation неправильный (меньше 0.001 или больше 0.3 мм)';
+			values['error.max_velocity.format'] = 'Максимальная скорость - ошибка формата';
+			values['error.max_velocity.small_or_big'] = 'Максимальная скорость неправильная (меньше 10 или больше 2000 мм/с)';
+			values['error.tool.format'] = 'Номер инструмента - ошибка формата';
+			values['error.tool.small_or_big'] = 'Номер инструмента неправильный (меньше 0 или больше 7)';
+			values['error.num_tools.format'] = 'Количество инструментов - ошибка формата';
+			values['error.num_tools.small_or_big'] = 'Количество инструментов неправильное (меньше 2 или больше 5)';
+			values['error.num_tools.not_fit'] = 'Башенки всех инструментов не помещаются на столе по X';
+			values['error.tool_temps.format'] = 'Температуры инструментов - ошибка формата';
+			values['error.tool_temps.too_many'] = 'Слишком много температур инструментов (больше 8)';
+			values['error.tool_temps.low_or_high'] = 'Температура инструмента неправильная (меньше 150 или больше 350 °C)';
+			values['warning.gcode.state_change'] = '%s навсегда меняет настройки принтера';
+			values['warning.gcode.relative_extrusion'] = 'M83 в стартовом G-коде - калибровка переключится на абсолютную экструзию, выберите относительный режим, чтобы сохранить его';
+			values['warning.gcode.no_homing'] = 'В стартовом G-коде нет парковки (G28)';
+			values['warning.gcode.no_heat_wait'] = 'Стартовый G-код не ждёт нагрева (M109/M190)';
+			values['warning.gcode.unknown_placeholders'] = 'Неизвестные плейсхолдеры: %s';
+			values['warning.gcode.foreign_commands'] = 'Команды, не поддерживаемые %s: %s';
+			values['warning.gcode.likely_firmware'] = 'G-код похож на написанный для %s';
 			values['error.hotend_temp.format'] = 'Температура хотэнда - ошибка формата';
 			values['error.hotend_temp.too_low'] = 'Температура хотэнда слишком низкая';
 			values['error.hotend_temp.too_high'] = 'Температура хотэнда слишком высокая';
 			values['error.bed_temp.format'] = 'Температура стола - ошибка формата: ';
 			values['error.bed_temp.too_high'] = 'Температура стола слишком высокая';
+			values['error.tower_hotend_temp.format'] = 'Температура хотэнда для башенки - ошибка формата';
+			values['error.tower_hotend_temp.low_or_high'] = 'Температура хотэнда для башенки слишком низкая или высокая (150-350 °C)';
+			values['error.tower_bed_temp.format'] = 'Температура стола для башенки - ошибка формата';
+			values['error.tower_bed_temp.too_high'] = 'Температура стола для башенки слишком высокая';
+			values['error.soak_time.format'] = 'Прогрев стола - ошибка формата';
+			values['error.soak_time.small_or_big'] = 'Время прогрева стола неправильное (меньше 0 или больше 60 мин)';
+			values['error.chamber_temp.format'] = 'Температура камеры - ошибка формата';
+			values['error.chamber_temp.too_high'] = 'Температура камеры слишком высокая';
+			values['error.chamber_temp.firmware'] = 'Repetier и Smoothieware не поддерживают температуру камеры';
+			values['error.chamber_temp.no_sensor'] = 'Не указан датчик камеры Klipper';
 			values['error.fan_speed.format'] = 'Скорость вентилятора - ошибка формата';
+			values['error.first_layer_fan.format'] = 'Вентилятор на первом слое - ошибка формата';
+			values['error.fan_ramp_layers.format'] = 'Слои разгона вентилятора - ошибка формата';
+			values['error.fan_ramp_layers.small_or_big'] = 'Количество слоёв разгона вентилятора неправильное (меньше 0 или больше 20)';
+			values['error.part_fan.format'] = 'Вентилятор обдува - ошибка формата (номер 0-9, для Klipper имя без пробелов)';
+			values['error.aux_fan_speed.format'] = 'Скорость доп. вентилятора - ошибка формата';
+			values['error.aux_fan.format'] = 'Дополнительный вентилятор - ошибка формата (номер 0-9, для Klipper имя без пробелов)';
+			values['error.aux_fan.same'] = 'Дополнительный вентилятор совпадает с вентилятором обдува';
 			values['error.line_width.format'] = 'Ширина линии - ошибка формата';
 			values['error.line_width.small_or_big'] = 'Неправильная ширина линии (меньше 0.1 или больше 2.0 мм)';
 			values['error.first_line_width.format'] = 'Ширина линии первого слоя - ошибка формата';
@@ -403,6 +1034,8 @@ function initLang(key) {
 			values['error.flow.format'] = 'Поток - ошибка формата';
 			values['error.flow.low_or_high'] = 'Ошибка значения: поток должен быть от 50 до 150%';
 			values['error.firmware.not_set'] = 'Ошибка формата: не выбрана прошивка';
+			values['error.volumetric_e.unsupported'] = 'Klipper не поддерживает объёмную экструзию';
+			values['error.firmware.no_linear_advance'] = 'Принтер сообщил, что Marlin собран без LIN_ADVANCE - калибровать нечего. Включите LIN_ADVANCE в Configuration_adv.h и перепрошейте принтер';
 			values['error.num_perimeters.format'] = 'Количество периметров - ошибка формата';
 			values['error.num_perimeters.small_or_big'] = 'Ошибка значения: количество периметров должно быть от 1 до 5';
 			values['error.fast_segment_speed.format'] = 'Скорость печати быстрых участков - ошибка формата';
@@ -410,9 +1043,16 @@ function initLang(key) {
 			values['error.slow_segment_speed.format'] = 'Скорость печати медленных участков - ошибка формата';
 			values['error.slow_segment_speed.small_or_big'] = 'Скорость печати медленных участков неверная (меньше 10 или больше 1000 мм/с)';
 			values['error.init_la.format'] = 'Начальное значение коэффициента LA - ошибка формата';
-			values['error.init_la.small_or_big'] = 'Начальное значение коэффициента LA неверное (меньше 0.0 или больше 2.0)';
+			values['error.init_la.small_or_big'] = 'Начальное значение коэффициента LA неверное';
 			values['error.end_la.format'] = 'Конечное значение коэффициента LA - ошибка формата';
-			values['error.end_la.small_or_big'] = 'Конечное значение коэффициента LA неверное (меньше 0.0 или больше 2.0)';
+			values['error.end_la.small_or_big'] = 'Конечное значение коэффициента LA неверное';
+			values['error.normal_k.format'] = 'Обычный K - ошибка формата';
+			values['error.normal_k.small_or_big'] = 'Значение обычного K неверное';
+			values['error.normal_k.not_set'] = 'Обычный K не указан, но он нужен для восстановления LA';
+			values['error.k_factor.range'] = ' (меньше %s или больше %s для выбранной прошивки)';
+			values['error.k_list.format'] = 'Список K - ошибка формата';
+			values['error.k_list.small_or_big'] = 'Длина списка K неправильная (меньше 2 или больше 100 значений)';
+			values['error.k_list.out_of_range'] = 'В списке K неправильные значения';
 			break;
 	}
 	
@@ -423,6 +1063,7 @@ function initLang(key) {
 		item.innerHTML = window.lang.getString(item.id);
 	}
 	document.getElementsByClassName('generate-button')[0].innerHTML = window.lang.getString('generator.generate_and_download');
+	document.getElementsByClassName('printer-button')[0].innerHTML = window.lang.getString('printer.read_button');
 	document.getElementsByClassName('reset-button')[0].innerHTML = window.lang.getString('generator.reset_to_default');
 	document.getElementsByClassName('navbar-direction')[0].innerHTML = window.lang.getString('navbar.back');
 	document.getElementById('generateButtonLoading').innerHTML = window.lang.getString('generator.generate_button_loading');
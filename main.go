@@ -8,28 +8,253 @@ import (
 	"syscall/js"
 )
 
-const filamentDiameter = 1.75
-
 var (
 	// Variables from web interface
 	bedX, bedY, zOffset, retractLength, firstLayerLineWidth, lineWidth, layerHeight, initKFactor, endKFactor, segmentHeight, smoothTime                                     float64
 	firmware, travelSpeed, hotendTemperature, bedTemperature, retractSpeed, cooling, firstLayerPrintSpeed, fastPrintSpeed, slowPrintSpeed, numSegments, numPerimeters, flow int
-	bedProbe, retracted, delta                                                                                                                                              bool
+	retracted, delta                                                                                                                                                        bool
 	startGcode, endGcode                                                                                                                                                    string
+	// Firmware specific variables
+	marlinLAVersion int
+	printerESteps   float64
+	// Tool variables
+	tool, numTools   int
+	multiTool        bool
+	toolTemperatures []int
+	// Bed probing variables
+	probeMode int
+	meshName  string
+	// Z-offset strategy
+	zOffsetMode int
+	// State restoring variables
+	restoreMode   int
+	normalKFactor float64
+	hasNormalK    bool
+	// Motion limits, zero means "don't change"
+	acceleration, jerk, junctionDeviation, maxVelocity float64
+	zTravelSpeed                                       int
+	// Model geometry, tower can grow to let fast sections reach their speed
+	modelWidth float64
+	growTower  bool
+	peakSpeed  float64
+	// K changes every layer instead of every segment
+	continuousK bool
+	// K of every segment from bottom to top
+	kSchedule int
+	kFactors  []float64
+	// Temperature schedule, tower temperatures are set after the raft
+	towerHotendTemperature, towerBedTemperature, chamberTemperature int
+	soakTime                                                        float64
+	tempWait, chamberWait                                           bool
+	chamberSensor                                                   string
+	// Fan variables, empty fan means default part cooling fan
+	partFan, auxFan                           string
+	firstLayerFan, fanRampLayers, auxFanSpeed int
+	fanOff                                    bool
+	// Minimum layer time, zero means "don't check"
+	minLayerTime  float64
+	layerTimeMode int
+	// Hotend flow limit, zero means "don't check"
+	maxFlow      float64
+	flowLimitCap bool
+	// Warnings about user G-code and print settings
+	generationWarnings []string
+	// Retraction variables
+	unretractSpeed                int
+	retractExtra, zHop, minTravel float64
+	zHopMode                      int
+	coastDistance, wipeDistance   float64
+	// Firmware retraction variables
+	firmwareRetraction, retractionSetup bool
+	// Extrusion mode variables
+	relativeE      bool
+	extrusionAfter int
+	volumetricE    bool
+	// E axis reset policy
+	eResetMode int
+	// Extrusion model variables
+	extrusionModel int
+	flowInE        bool
+	// Filament variables
+	filamentType                                     string
+	filamentDiameter, filamentDensity, filamentPrice float64
+	// Travel planner variables
+	avoidCrossing bool
+	printedAreas  []Rect
+	// Statistics, collected by dry run of generator
+	dryRun         bool
+	extrudedVolume float64
+	travelDistance float64
 	// Current variables
 	currentCoordinates Point
 	currentSpeed       int
 	currentE           float64
+	currentTool        int
+	primedTools        []bool
 )
 
 const caliVersion = "v1.4"
 
+// Minimal distance between centers of towers when calibrating several tools
+const minTowerSpacing = 60.0
+
+// Side of the sacrificial cooling tower printed behind towers
+const coolingTowerSize = 15.0
+
+// Lengths of slow sections on right, front and left sides of the tower
+const (
+	rightShortLine = 20.0
+	frontShortLine = 2.0
+	leftShortLine  = 0.2
+)
+
+const maxTools = 8
+
+// Firmware dialects, in the order of radio buttons on the page
+const (
+	firmwareMarlin = iota
+	firmwareKlipper
+	firmwareRRF
+	firmwareRepetier
+	firmwareSmoothieware
+)
+
+// Bed probing modes
+const (
+	probeNone = iota
+	probeFull
+	probeArea
+	probePrusa
+	probeLoad
+)
+
+// Z-offset strategies
+const (
+	zOffsetFirmware = iota
+	zOffsetHomeOffset
+	zOffsetBake
+	zOffsetG92
+)
+
+// Modes of restoring printer state after the test
+const (
+	restoreNone = iota
+	restoreSaved
+	restoreNormal
+)
+
+var firmwareNames = []string{"Marlin", "Klipper", "RRF", "Repetier", "Smoothieware"}
+
+// Form radio buttons of firmwares in order of firmware constants
+var firmwareRadios = []string{"k3d_la_firmwareMarlin", "k3d_la_firmwareKlipper", "k3d_la_firmwareRRF", "k3d_la_firmwareRepetier", "k3d_la_firmwareSmoothieware"}
+
+// Placeholders, that are replaced in start and end G-code
+var knownPlaceholders = []string{"$BEDTEMP", "$HOTTEMP", "$G29", "$FLOW", "$TOWERHOTTEMP", "$TOWERBEDTEMP", "$CHAMBERTEMP", "$SOAKTIME"}
+
+// FilamentProfile holds typical settings of filament material
+type FilamentProfile struct {
+	Name                              string
+	Density                           float64
+	HotendTemperature, BedTemperature int
+	// end of the default K range is multiplied by KScale, soft filaments need much higher K
+	KScale float64
+}
+
+var filamentProfiles = map[string]FilamentProfile{
+	"pla":  {"PLA", 1.24, 210, 60, 1.0},
+	"petg": {"PETG", 1.27, 240, 75, 1.5},
+	"abs":  {"ABS", 1.04, 250, 100, 1.0},
+	"asa":  {"ASA", 1.07, 255, 100, 1.0},
+	"tpu":  {"TPU", 1.21, 230, 40, 5.0},
+}
+
+// Z-hop modes
+const (
+	zHopNormal = iota
+	zHopRamp
+	zHopSpiral
+)
+
+// Ways to keep minimum layer time
+const (
+	layerTimeSlow = iota
+	layerTimeDwell
+	layerTimeTower
+)
+
+// Ways to choose K of segments
+const (
+	kScheduleLinear = iota
+	kScheduleLog
+	kScheduleList
+)
+
+// E axis reset policies
+const (
+	eResetNone = iota
+	eResetLayer
+	eResetSegment
+)
+
+// Line cross-section models
+const (
+	extrusionRectangle = iota
+	extrusionRounded
+)
+
+// Extrusion mode set after calibration
+const (
+	extrusionAfterNone = iota
+	extrusionAfterAbsolute
+	extrusionAfterRelative
+)
+
+// Marlin linear advance versions
+const (
+	marlinLA15 = iota
+	marlinLA10
+	marlinLA15Slot
+)
+
 type Point struct {
 	X float64
 	Y float64
 	Z float64
 }
 
+// Rect is an area printed up to height Z
+type Rect struct {
+	MinX, MinY, MaxX, MaxY float64
+	Z                      float64
+}
+
+func (r Rect) contains(p Point) bool {
+	return p.X >= r.MinX && p.X <= r.MaxX && p.Y >= r.MinY && p.Y <= r.MaxY
+}
+
+// PrinterInfo holds what the printer reported about itself in answers to M115, M503, M900, M211 and M572
+type PrinterInfo struct {
+	Firmware     int
+	FirmwareName string
+	NumTools     int
+	LinAdvance   int
+	KFactor      float64
+	HasKFactor   bool
+	BedX, BedY   float64
+	HasBedSize   bool
+	HasFirmware  bool
+	HasExtruders bool
+	ESteps       float64
+	HasESteps    bool
+}
+
+// Linear advance support reported by the printer
+const (
+	linAdvanceUnknown = iota
+	linAdvanceEnabled
+	linAdvanceDisabled
+)
+
 func main() {
 	c := make(chan struct{})
 	registerFunctions()
@@ -40,6 +265,9 @@ func registerFunctions() {
 	js.Global().Set("generate", js.FuncOf(generate))
 	js.Global().Set("checkGo", js.FuncOf(checkJs))
 	js.Global().Set("checkSegments", js.FuncOf(checkSegments))
+	js.Global().Set("setDefaultKRange", js.FuncOf(setDefaultKRange))
+	js.Global().Set("applyPrinterInfo", js.FuncOf(applyPrinterInfo))
+	js.Global().Set("setFilamentDefaults", js.FuncOf(setFilamentDefaults))
 }
 
 func setErrorDescription(doc js.Value, lang js.Value, key string, curErr string, hasErr bool, allowModify bool) {
@@ -56,6 +284,18 @@ func setErrorDescription(doc js.Value, lang js.Value, key string, curErr string,
 	}
 }
 
+func setWarningDescription(doc js.Value, lang js.Value, key string, warnings []string, allowModify bool) {
+	if !allowModify {
+		return
+	}
+	el := doc.Call("getElementById", key)
+	if len(warnings) > 0 {
+		el.Set("innerHTML", lang.Call("getString", key).String()+"<br><span class=\"inline-warning\">"+strings.Join(warnings, "<br>")+"</span>")
+	} else {
+		el.Set("innerHTML", lang.Call("getString", key).String())
+	}
+}
+
 func check(showErrorBox bool, allowModify bool) bool {
 	errorString := ""
 	doc := js.Global().Get("document")
@@ -99,22 +339,40 @@ func check(showErrorBox bool, allowModify bool) bool {
 		retErr = true
 	}
 
-	docMarlin := doc.Call("getElementById", "k3d_la_firmwareMarlin").Get("checked").Bool()
-	docKlipper := doc.Call("getElementById", "k3d_la_firmwareKlipper").Get("checked").Bool()
-	docRRF := doc.Call("getElementById", "k3d_la_firmwareRRF").Get("checked").Bool()
-	if docMarlin {
-		firmware = 0
-	} else if docKlipper {
-		firmware = 1
-	} else if docRRF {
-		firmware = 2
+	if docFirmware, ok := selectedFirmware(doc); ok {
+		firmware = docFirmware
 	} else {
-		errorString = errorString + lang.Call("getString", "error.firmware.not_set").String() + "\n"
+		curErr, hasErr = lang.Call("getString", "error.firmware.not_set").String(), true
+	}
+	// printer told us, that it was built without linear advance
+	if firmware == firmwareMarlin && !hasErr && doc.Call("getElementById", "k3d_la_printerLinAdvance").Get("value").String() == fmt.Sprint(linAdvanceDisabled) {
+		curErr, hasErr = lang.Call("getString", "error.firmware.no_linear_advance").String(), true
+	}
+	setErrorDescription(doc, lang, "table.firmware.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
 	}
 
+	marlinLAVersion = parseMarlinLAVersion(doc.Call("getElementById", "k3d_la_marlinLA").Get("value").String())
+	// E steps read from the printer, 0 if unknown
+	printerESteps, _ = parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_printerESteps").Get("value").String())
+
 	delta = doc.Call("getElementById", "k3d_la_delta").Get("checked").Bool()
 
-	bedProbe = doc.Call("getElementById", "k3d_la_g29").Get("checked").Bool()
+	probeMode = parseProbeMode(doc.Call("getElementById", "k3d_la_bedProbe").Get("value").String())
+
+	meshName = strings.TrimSpace(doc.Call("getElementById", "k3d_la_meshName").Get("value").String())
+	if strings.ContainsAny(meshName, " \"'\n;") {
+		curErr, hasErr = lang.Call("getString", "error.mesh_name.format").String(), true
+	}
+	setErrorDescription(doc, lang, "table.mesh_name.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
 
 	docTravelSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_travelSpeed").Get("value").String())
 	if err != nil {
@@ -132,327 +390,1319 @@ func check(showErrorBox bool, allowModify bool) bool {
 		retErr = true
 	}
 
-	// Параметры филамента
+	// Параметры инструментов
 
-	docHotTemp, err := parseInputToInt(doc.Call("getElementById", "k3d_la_hotendTemperature").Get("value").String())
+	docTool, err := parseInputToInt(doc.Call("getElementById", "k3d_la_tool").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.hotend_temp.format").String(), true
-	} else if docHotTemp < 150 {
-		curErr, hasErr = lang.Call("getString", "error.hotend_temp.too_low").String(), true
-	} else if docHotTemp > 350 {
-		curErr, hasErr = lang.Call("getString", "error.hotend_temp.too_high").String(), true
+		curErr, hasErr = lang.Call("getString", "error.tool.format").String(), true
+	} else if docTool < 0 || docTool >= maxTools {
+		curErr, hasErr = lang.Call("getString", "error.tool.small_or_big").String(), true
 	} else {
-		hotendTemperature = docHotTemp
+		tool = docTool
 	}
-
-	setErrorDescription(doc, lang, "table.hotend_temp.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.tool.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docBedTemp, err := parseInputToInt(doc.Call("getElementById", "k3d_la_bedTemperature").Get("value").String())
+	multiTool = doc.Call("getElementById", "k3d_la_multiTool").Get("checked").Bool()
+
+	docNumTools, err := parseInputToInt(doc.Call("getElementById", "k3d_la_numTools").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.bed_temp.format").String()+err.Error(), true
-	} else if docBedTemp > 150 {
-		curErr, hasErr = lang.Call("getString", "error.bed_temp.too_high").String(), true
+		curErr, hasErr = lang.Call("getString", "error.num_tools.format").String(), true
+	} else if docNumTools < 2 || docNumTools > 5 {
+		curErr, hasErr = lang.Call("getString", "error.num_tools.small_or_big").String(), true
+	} else if multiTool && float64(docNumTools)*minTowerSpacing > bedX-30.0 {
+		curErr, hasErr = lang.Call("getString", "error.num_tools.not_fit").String(), true
 	} else {
-		bedTemperature = docBedTemp
+		numTools = docNumTools
 	}
-
-	setErrorDescription(doc, lang, "table.bed_temp.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.num_tools.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docCooling, err := parseInputToInt(doc.Call("getElementById", "k3d_la_cooling").Get("value").String())
+	docZTravelSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_zTravelSpeed").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.fan_speed.format").String(), true
+		curErr, hasErr = lang.Call("getString", "error.z_travel_speed.format").String(), true
+	} else if docZTravelSpeed < 1 || docZTravelSpeed > 100 {
+		curErr, hasErr = lang.Call("getString", "error.z_travel_speed.slow_or_fast").String(), true
 	} else {
-		docCooling = int(float64(docCooling) * 2.55)
-		if docCooling < 0 {
-			docCooling = 0
-		} else if docCooling > 255 {
-			docCooling = 255
-		}
-		cooling = docCooling
+		zTravelSpeed = docZTravelSpeed
 	}
-	setErrorDescription(doc, lang, "table.fan_speed.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.z_travel_speed.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	retractLength = 1.0
-
-	retractSpeed = 30.0
-
-	docFlow, err := parseInputToInt(doc.Call("getElementById", "k3d_la_flow").Get("value").String())
+	docAcceleration, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_acceleration").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.flow.format").String(), true
-	} else if docFlow < 50 || docFlow > 150 {
-		curErr, hasErr = lang.Call("getString", "error.flow.low_or_high").String(), true
+		curErr, hasErr = lang.Call("getString", "error.acceleration.format").String(), true
+	} else if docAcceleration != 0 && (docAcceleration < 100 || docAcceleration > 50000) {
+		curErr, hasErr = lang.Call("getString", "error.acceleration.small_or_big").String(), true
 	} else {
-		flow = docFlow
+		acceleration = docAcceleration
 	}
-	setErrorDescription(doc, lang, "table.flow.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.acceleration.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	// Параметры первого слоя
-
-	docFirstLayerLineWidth, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_firstLayerLineWidth").Get("value").String())
+	docJerk, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_jerk").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.first_line_width.format").String(), true
-	} else if docFirstLayerLineWidth < 0.1 || docFirstLayerLineWidth > 2.0 {
-		curErr, hasErr = lang.Call("getString", "error.first_line_width.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.jerk.format").String(), true
+	} else if docJerk != 0 && (docJerk < 1 || docJerk > 50) {
+		curErr, hasErr = lang.Call("getString", "error.jerk.small_or_big").String(), true
 	} else {
-		firstLayerLineWidth = docFirstLayerLineWidth
+		jerk = docJerk
 	}
-	setErrorDescription(doc, lang, "table.first_line_width.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.jerk.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docFirstLayerPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_firstLayerSpeed").Get("value").String())
+	docJunctionDeviation, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_junctionDeviation").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.first_print_speed.format").String(), true
-	} else if docFirstLayerPrintSpeed < 10 || docFirstLayerPrintSpeed > 1000 {
-		curErr, hasErr = lang.Call("getString", "error.first_print_speed.slow_or_fast").String(), true
+		curErr, hasErr = lang.Call("getString", "error.junction_deviation.format").String(), true
+	} else if docJunctionDeviation != 0 && (docJunctionDeviation < 0.001 || docJunctionDeviation > 0.3) {
+		curErr, hasErr = lang.Call("getString", "error.junction_deviation.small_or_big").String(), true
 	} else {
-		firstLayerPrintSpeed = docFirstLayerPrintSpeed
+		junctionDeviation = docJunctionDeviation
 	}
-	setErrorDescription(doc, lang, "table.first_print_speed.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.junction_deviation.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docZOffset, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_zOffset").Get("value").String())
+	docMaxVelocity, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_maxVelocity").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.z_offset.format").String(), true
-	} else if docZOffset < -0.5 || docZOffset > 0.5 {
-		curErr, hasErr = lang.Call("getString", "error.z_offset.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.max_velocity.format").String(), true
+	} else if docMaxVelocity != 0 && (docMaxVelocity < 10 || docMaxVelocity > 2000) {
+		curErr, hasErr = lang.Call("getString", "error.max_velocity.small_or_big").String(), true
 	} else {
-		zOffset = docZOffset
+		maxVelocity = docMaxVelocity
 	}
-	setErrorDescription(doc, lang, "table.z_offset.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.max_velocity.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	// Параметры модели
+	firmwareRetraction = doc.Call("getElementById", "k3d_la_firmwareRetraction").Get("checked").Bool()
+	retractionSetup = doc.Call("getElementById", "k3d_la_retractionSetup").Get("checked").Bool()
 
-	docNumPerimeters, err := parseInputToInt(doc.Call("getElementById", "k3d_la_numPerimeters").Get("value").String())
-	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.num_perimeters.format").String(), true
-	} else if docNumPerimeters < 1 || docNumPerimeters > 5 {
-		curErr, hasErr = lang.Call("getString", "error.num_perimeters.small_or_big").String(), true
+	relativeE = doc.Call("getElementById", "k3d_la_extrusionMode").Get("value").String() == "relative"
+	extrusionAfter = parseExtrusionAfter(doc.Call("getElementById", "k3d_la_extrusionAfter").Get("value").String())
+	eResetMode = parseEResetMode(doc.Call("getElementById", "k3d_la_eReset").Get("value").String())
+
+	docVolumetricE := doc.Call("getElementById", "k3d_la_volumetricE").Get("checked").Bool()
+	if docVolumetricE && firmware == firmwareKlipper {
+		curErr, hasErr = lang.Call("getString", "error.volumetric_e.unsupported").String(), true
 	} else {
-		numPerimeters = docNumPerimeters
+		volumetricE = docVolumetricE
 	}
-	setErrorDescription(doc, lang, "table.num_perimeters.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.volumetric_e.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docLineWidth, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_lineWidth").Get("value").String())
+	// Параметры филамента
+
+	filamentType = doc.Call("getElementById", "k3d_la_filamentType").Get("value").String()
+
+	docFilamentDiameter, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_filamentDiameter").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.line_width.format").String(), true
-	} else if docLineWidth < 0.1 || docLineWidth > 2.0 {
-		curErr, hasErr = lang.Call("getString", "error.line_width.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.filament_diameter.format").String(), true
+	} else if docFilamentDiameter < 1 || docFilamentDiameter > 3.5 {
+		curErr, hasErr = lang.Call("getString", "error.filament_diameter.small_or_big").String(), true
 	} else {
-		lineWidth = docLineWidth
+		filamentDiameter = docFilamentDiameter
 	}
-	setErrorDescription(doc, lang, "table.line_width.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.filament_diameter.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docLayerHeight, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_layerHeight").Get("value").String())
+	docFilamentDensity, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_filamentDensity").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.layer_height.format").String(), true
-	} else if docLayerHeight < 0.05 || docLayerHeight > 1.2 {
-		curErr, hasErr = lang.Call("getString", "error.layer_height.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.filament_density.format").String(), true
+	} else if docFilamentDensity < 0.5 || docFilamentDensity > 5 {
+		curErr, hasErr = lang.Call("getString", "error.filament_density.small_or_big").String(), true
 	} else {
-		layerHeight = docLayerHeight
+		filamentDensity = docFilamentDensity
 	}
-	setErrorDescription(doc, lang, "table.layer_height.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.filament_density.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docFastPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_fastPrintSpeed").Get("value").String())
+	docFilamentPrice, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_filamentPrice").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.fast_segment_speed.format").String(), true
-	} else if docFastPrintSpeed < 10 || docFastPrintSpeed > 1000 {
-		curErr, hasErr = lang.Call("getString", "error.fast_segment_speed.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.filament_price.format").String(), true
+	} else if docFilamentPrice < 0 {
+		curErr, hasErr = lang.Call("getString", "error.filament_price.negative").String(), true
 	} else {
-		fastPrintSpeed = docFastPrintSpeed
+		filamentPrice = docFilamentPrice
 	}
-	setErrorDescription(doc, lang, "table.fast_segment_speed.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.filament_price.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docSlowPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_slowPrintSpeed").Get("value").String())
+	docHotTemp, err := parseInputToInt(doc.Call("getElementById", "k3d_la_hotendTemperature").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.slow_segment_speed.format").String(), true
-	} else if docSlowPrintSpeed < 10 || docSlowPrintSpeed > 1000 {
-		curErr, hasErr = lang.Call("getString", "error.slow_segment_speed.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.hotend_temp.format").String(), true
+	} else if docHotTemp < 150 {
+		curErr, hasErr = lang.Call("getString", "error.hotend_temp.too_low").String(), true
+	} else if docHotTemp > 350 {
+		curErr, hasErr = lang.Call("getString", "error.hotend_temp.too_high").String(), true
 	} else {
-		slowPrintSpeed = docSlowPrintSpeed
+		hotendTemperature = docHotTemp
 	}
-	setErrorDescription(doc, lang, "table.slow_segment_speed.description", curErr, hasErr, allowModify)
+
+	setErrorDescription(doc, lang, "table.hotend_temp.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	// Параметры калибровки
-
-	docInitKFactor, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_initKFactor").Get("value").String())
+	docToolTemperatures, err := parseInputToIntList(doc.Call("getElementById", "k3d_la_toolTemperatures").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.init_la.format").String(), true
-	} else if docInitKFactor < 0.0 || docInitKFactor > 2.0 {
-		curErr, hasErr = lang.Call("getString", "error.init_la.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.tool_temps.format").String(), true
+	} else if len(docToolTemperatures) > maxTools {
+		curErr, hasErr = lang.Call("getString", "error.tool_temps.too_many").String(), true
 	} else {
-		initKFactor = docInitKFactor
+		for _, temp := range docToolTemperatures {
+			if temp < 150 || temp > 350 {
+				curErr, hasErr = lang.Call("getString", "error.tool_temps.low_or_high").String(), true
+			}
+		}
+		if !hasErr {
+			toolTemperatures = docToolTemperatures
+		}
 	}
-	setErrorDescription(doc, lang, "table.init_la.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.tool_temps.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docEndKFactor, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_endKFactor").Get("value").String())
+	docBedTemp, err := parseInputToInt(doc.Call("getElementById", "k3d_la_bedTemperature").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.end_la.format").String(), true
-	} else if docEndKFactor < 0.0 || docEndKFactor > 2.0 {
-		curErr, hasErr = lang.Call("getString", "error.end_la.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.bed_temp.format").String()+err.Error(), true
+	} else if docBedTemp > 150 {
+		curErr, hasErr = lang.Call("getString", "error.bed_temp.too_high").String(), true
 	} else {
-		endKFactor = docEndKFactor
+		bedTemperature = docBedTemp
 	}
-	setErrorDescription(doc, lang, "table.end_la.description", curErr, hasErr, allowModify)
+
+	setErrorDescription(doc, lang, "table.bed_temp.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docNumSegment, err := parseInputToInt(doc.Call("getElementById", "k3d_la_numSegments").Get("value").String())
+	// empty tower temperatures are the same as first layer ones
+	docTowerHotTemp, err := parseOptionalInputToInt(doc.Call("getElementById", "k3d_la_towerHotendTemperature").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.num_segments.format").String(), true
-	} else if docNumSegment < 2 || docNumSegment > 100 {
-		curErr, hasErr = lang.Call("getString", "error.num_segments.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.tower_hotend_temp.format").String(), true
+	} else if docTowerHotTemp != 0 && (docTowerHotTemp < 150 || docTowerHotTemp > 350) {
+		curErr, hasErr = lang.Call("getString", "error.tower_hotend_temp.low_or_high").String(), true
+	} else if docTowerHotTemp == 0 {
+		towerHotendTemperature = hotendTemperature
 	} else {
-		numSegments = docNumSegment
+		towerHotendTemperature = docTowerHotTemp
 	}
-	setErrorDescription(doc, lang, "table.num_segments.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.tower_hotend_temp.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docSegmentHeight, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_segmentHeight").Get("value").String())
+	docTowerBedTemp, err := parseOptionalInputToInt(doc.Call("getElementById", "k3d_la_towerBedTemperature").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.segment_height.format").String(), true
-	} else if docSegmentHeight < 0.5 || docSegmentHeight > 10.0 {
-		curErr, hasErr = lang.Call("getString", "error.segment_height.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.tower_bed_temp.format").String(), true
+	} else if docTowerBedTemp < 0 || docTowerBedTemp > 150 {
+		curErr, hasErr = lang.Call("getString", "error.tower_bed_temp.too_high").String(), true
+	} else if docTowerBedTemp == 0 {
+		towerBedTemperature = bedTemperature
 	} else {
-		segmentHeight = docSegmentHeight
+		towerBedTemperature = docTowerBedTemp
 	}
-	setErrorDescription(doc, lang, "table.segment_height.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.tower_bed_temp.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	docSmoothTime, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_smoothTime").Get("value").String())
+	tempWait = doc.Call("getElementById", "k3d_la_tempWait").Get("checked").Bool()
+
+	docSoakTime, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_soakTime").Get("value").String())
 	if err != nil {
-		curErr, hasErr = lang.Call("getString", "error.smooth_time.format").String(), true
-	} else if docSmoothTime < 0.005 || docSmoothTime > 0.2 {
-		curErr, hasErr = lang.Call("getString", "error.smooth_time.small_or_big").String(), true
+		curErr, hasErr = lang.Call("getString", "error.soak_time.format").String(), true
+	} else if docSoakTime < 0 || docSoakTime > 60 {
+		curErr, hasErr = lang.Call("getString", "error.soak_time.small_or_big").String(), true
 	} else {
-		smoothTime = docSmoothTime
+		soakTime = docSoakTime
 	}
-	setErrorDescription(doc, lang, "table.smooth_time.description", curErr, hasErr, allowModify)
+	setErrorDescription(doc, lang, "table.soak_time.description", curErr, hasErr, allowModify)
 	if hasErr {
 		errorString = errorString + curErr + "\n"
 		hasErr = false
 		retErr = true
 	}
 
-	startGcode = doc.Call("getElementById", "k3d_la_startGcode").Get("value").String()
-	endGcode = doc.Call("getElementById", "k3d_la_endGcode").Get("value").String()
-
-	if !showErrorBox {
-		return !retErr
-	}
-
-	// end check of parameters
-	if !retErr {
-		println("OK")
-		return true
+	// Repetier and Smoothieware don't control chamber heaters, Klipper needs a sensor to wait for
+	chamberSensor = strings.TrimSpace(doc.Call("getElementById", "k3d_la_chamberSensor").Get("value").String())
+	docChamberTemp, err := parseOptionalInputToInt(doc.Call("getElementById", "k3d_la_chamberTemperature").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.chamber_temp.format").String(), true
+	} else if docChamberTemp < 0 || docChamberTemp > 90 {
+		curErr, hasErr = lang.Call("getString", "error.chamber_temp.too_high").String(), true
+	} else if docChamberTemp != 0 && (firmware == firmwareRepetier || firmware == firmwareSmoothieware) {
+		curErr, hasErr = lang.Call("getString", "error.chamber_temp.firmware").String(), true
+	} else if docChamberTemp != 0 && firmware == firmwareKlipper && chamberSensor == "" {
+		curErr, hasErr = lang.Call("getString", "error.chamber_temp.no_sensor").String(), true
 	} else {
-		println(errorString)
-		js.Global().Call("showError", errorString)
-		return false
+		chamberTemperature = docChamberTemp
 	}
-}
-
-func write(str ...string) {
-	for i := 0; i < len(str); i++ {
-		js.Global().Call("writeToFile", str[i])
+	setErrorDescription(doc, lang, "table.chamber_temp.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
 	}
-}
-
-func checkSegments(this js.Value, i []js.Value) interface{} {
-	if check(false, false) {
-		lang := js.Global().Get("lang")
-		segmentStr := lang.Call("getString", "generator.segment").String()
 
-		deltaKFactor := math.Abs((endKFactor - initKFactor) / float64(numSegments-1))
-		maxKFactor := math.Max(initKFactor, endKFactor)
+	chamberWait = doc.Call("getElementById", "k3d_la_chamberWait").Get("checked").Bool()
 
-		// generate calibration parameters
-		caliParams := ""
-		for i := 0; i < numSegments; i++ {
-			caliParams += fmt.Sprintf(segmentStr, numSegments-i, fmt.Sprint(roundFloat(maxKFactor-deltaKFactor*float64(i), 3)))
+	docCooling, err := parseInputToInt(doc.Call("getElementById", "k3d_la_cooling").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.fan_speed.format").String(), true
+	} else {
+		docCooling = int(float64(docCooling) * 2.55)
+		if docCooling < 0 {
+			docCooling = 0
+		} else if docCooling > 255 {
+			docCooling = 255
 		}
+		cooling = docCooling
+	}
+	setErrorDescription(doc, lang, "table.fan_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
 
-		js.Global().Call("setSegmentsPreview", caliParams)
+	docFirstLayerFan, err := parseInputToInt(doc.Call("getElementById", "k3d_la_firstLayerFan").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.first_layer_fan.format").String(), true
 	} else {
-		js.Global().Call("setSegmentsPreview", js.ValueOf(nil))
-		check(false, true)
+		docFirstLayerFan = int(float64(docFirstLayerFan) * 2.55)
+		if docFirstLayerFan < 0 {
+			docFirstLayerFan = 0
+		} else if docFirstLayerFan > 255 {
+			docFirstLayerFan = 255
+		}
+		firstLayerFan = docFirstLayerFan
+	}
+	setErrorDescription(doc, lang, "table.first_layer_fan.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docFanRampLayers, err := parseInputToInt(doc.Call("getElementById", "k3d_la_fanRampLayers").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.fan_ramp_layers.format").String(), true
+	} else if docFanRampLayers < 0 || docFanRampLayers > 20 {
+		curErr, hasErr = lang.Call("getString", "error.fan_ramp_layers.small_or_big").String(), true
+	} else {
+		fanRampLayers = docFanRampLayers
+	}
+	setErrorDescription(doc, lang, "table.fan_ramp_layers.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docPartFan := strings.TrimSpace(doc.Call("getElementById", "k3d_la_partFan").Get("value").String())
+	if !isValidFan(docPartFan) {
+		curErr, hasErr = lang.Call("getString", "error.part_fan.format").String(), true
+	} else {
+		partFan = docPartFan
+	}
+	setErrorDescription(doc, lang, "table.part_fan.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docAuxFanSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_auxFanSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.aux_fan_speed.format").String(), true
+	} else {
+		docAuxFanSpeed = int(float64(docAuxFanSpeed) * 2.55)
+		if docAuxFanSpeed < 0 {
+			docAuxFanSpeed = 0
+		} else if docAuxFanSpeed > 255 {
+			docAuxFanSpeed = 255
+		}
+		auxFanSpeed = docAuxFanSpeed
+	}
+	setErrorDescription(doc, lang, "table.aux_fan_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	// auxiliary fan is switched on only when it is set, default fan is already used for part cooling
+	docAuxFan := strings.TrimSpace(doc.Call("getElementById", "k3d_la_auxFan").Get("value").String())
+	if !isValidFan(docAuxFan) {
+		curErr, hasErr = lang.Call("getString", "error.aux_fan.format").String(), true
+	} else if docAuxFan != "" && docAuxFan == partFan {
+		curErr, hasErr = lang.Call("getString", "error.aux_fan.same").String(), true
+	} else {
+		auxFan = docAuxFan
+	}
+	setErrorDescription(doc, lang, "table.aux_fan.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	fanOff = doc.Call("getElementById", "k3d_la_fanOff").Get("checked").Bool()
+
+	docRetractLength, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_retractLength").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.retract_length.format").String(), true
+	} else if docRetractLength < 0 || docRetractLength > 10 {
+		curErr, hasErr = lang.Call("getString", "error.retract_length.small_or_big").String(), true
+	} else {
+		retractLength = docRetractLength
+	}
+	setErrorDescription(doc, lang, "table.retract_length.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docRetractSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_retractSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.retract_speed.format").String(), true
+	} else if docRetractSpeed < 1 || docRetractSpeed > 150 {
+		curErr, hasErr = lang.Call("getString", "error.retract_speed.small_or_big").String(), true
+	} else {
+		retractSpeed = docRetractSpeed
+	}
+	setErrorDescription(doc, lang, "table.retract_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docUnretractSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_unretractSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.unretract_speed.format").String(), true
+	} else if docUnretractSpeed < 1 || docUnretractSpeed > 150 {
+		curErr, hasErr = lang.Call("getString", "error.unretract_speed.small_or_big").String(), true
+	} else {
+		unretractSpeed = docUnretractSpeed
+	}
+	setErrorDescription(doc, lang, "table.unretract_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docRetractExtra, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_retractExtra").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.retract_extra.format").String(), true
+	} else if docRetractExtra < -1 || docRetractExtra > 2 {
+		curErr, hasErr = lang.Call("getString", "error.retract_extra.small_or_big").String(), true
+	} else {
+		retractExtra = docRetractExtra
+	}
+	setErrorDescription(doc, lang, "table.retract_extra.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docZHop, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_zHop").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.z_hop.format").String(), true
+	} else if docZHop < 0 || docZHop > 5 {
+		curErr, hasErr = lang.Call("getString", "error.z_hop.small_or_big").String(), true
+	} else {
+		zHop = docZHop
+	}
+	setErrorDescription(doc, lang, "table.z_hop.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docMinTravel, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_minTravel").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.min_travel.format").String(), true
+	} else if docMinTravel < 0 || docMinTravel > 50 {
+		curErr, hasErr = lang.Call("getString", "error.min_travel.small_or_big").String(), true
+	} else {
+		minTravel = docMinTravel
+	}
+	setErrorDescription(doc, lang, "table.min_travel.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	zHopMode = parseZHopMode(doc.Call("getElementById", "k3d_la_zHopMode").Get("value").String())
+	avoidCrossing = doc.Call("getElementById", "k3d_la_avoidCrossing").Get("checked").Bool()
+
+	docCoastDistance, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_coastDistance").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.coast_distance.format").String(), true
+	} else if docCoastDistance < 0 || docCoastDistance > 5 {
+		curErr, hasErr = lang.Call("getString", "error.coast_distance.small_or_big").String(), true
+	} else {
+		coastDistance = docCoastDistance
+	}
+	setErrorDescription(doc, lang, "table.coast_distance.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docWipeDistance, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_wipeDistance").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.wipe_distance.format").String(), true
+	} else if docWipeDistance < 0 || docWipeDistance > 10 {
+		curErr, hasErr = lang.Call("getString", "error.wipe_distance.small_or_big").String(), true
+	} else {
+		wipeDistance = docWipeDistance
+	}
+	setErrorDescription(doc, lang, "table.wipe_distance.description", curErr, hasErr, allowModify)
+	wipeDistanceValid := !hasErr
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	// firmware retraction can't be combined with movement, so there is no wipe while retracting
+	wipeWarnings := make([]string, 0)
+	if firmwareRetraction && wipeDistance > 0 && wipeDistanceValid {
+		wipeDistance = 0
+		wipeWarnings = append(wipeWarnings, lang.Call("getString", "warning.wipe_distance.firmware_retraction").String())
+	}
+	setWarningDescription(doc, lang, "table.wipe_distance.description", wipeWarnings, allowModify && wipeDistanceValid)
+
+	docFlow, err := parseInputToInt(doc.Call("getElementById", "k3d_la_flow").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.flow.format").String(), true
+	} else if docFlow < 50 || docFlow > 150 {
+		curErr, hasErr = lang.Call("getString", "error.flow.low_or_high").String(), true
+	} else {
+		flow = docFlow
+	}
+	setErrorDescription(doc, lang, "table.flow.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	extrusionModel = parseExtrusionModel(doc.Call("getElementById", "k3d_la_extrusionModel").Get("value").String())
+	flowInE = doc.Call("getElementById", "k3d_la_flowInE").Get("checked").Bool()
+
+	// Параметры первого слоя
+
+	docFirstLayerLineWidth, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_firstLayerLineWidth").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.first_line_width.format").String(), true
+	} else if docFirstLayerLineWidth < 0.1 || docFirstLayerLineWidth > 2.0 {
+		curErr, hasErr = lang.Call("getString", "error.first_line_width.small_or_big").String(), true
+	} else {
+		firstLayerLineWidth = docFirstLayerLineWidth
+	}
+	setErrorDescription(doc, lang, "table.first_line_width.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docFirstLayerPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_firstLayerSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.first_print_speed.format").String(), true
+	} else if docFirstLayerPrintSpeed < 10 || docFirstLayerPrintSpeed > 1000 {
+		curErr, hasErr = lang.Call("getString", "error.first_print_speed.slow_or_fast").String(), true
+	} else {
+		firstLayerPrintSpeed = docFirstLayerPrintSpeed
+	}
+	setErrorDescription(doc, lang, "table.first_print_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docZOffset, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_zOffset").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.z_offset.format").String(), true
+	} else if docZOffset < -0.5 || docZOffset > 0.5 {
+		curErr, hasErr = lang.Call("getString", "error.z_offset.small_or_big").String(), true
+	} else {
+		zOffset = docZOffset
+	}
+	setErrorDescription(doc, lang, "table.z_offset.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	zOffsetMode = parseZOffsetMode(doc.Call("getElementById", "k3d_la_zOffsetMode").Get("value").String())
+
+	// Параметры модели
+
+	docNumPerimeters, err := parseInputToInt(doc.Call("getElementById", "k3d_la_numPerimeters").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.num_perimeters.format").String(), true
+	} else if docNumPerimeters < 1 || docNumPerimeters > 5 {
+		curErr, hasErr = lang.Call("getString", "error.num_perimeters.small_or_big").String(), true
+	} else {
+		numPerimeters = docNumPerimeters
+	}
+	setErrorDescription(doc, lang, "table.num_perimeters.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docLineWidth, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_lineWidth").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.line_width.format").String(), true
+	} else if docLineWidth < 0.1 || docLineWidth > 2.0 {
+		curErr, hasErr = lang.Call("getString", "error.line_width.small_or_big").String(), true
+	} else {
+		lineWidth = docLineWidth
+	}
+	setErrorDescription(doc, lang, "table.line_width.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docLayerHeight, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_layerHeight").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.layer_height.format").String(), true
+	} else if docLayerHeight < 0.05 || docLayerHeight > 1.2 {
+		curErr, hasErr = lang.Call("getString", "error.layer_height.small_or_big").String(), true
+	} else {
+		layerHeight = docLayerHeight
+	}
+	setErrorDescription(doc, lang, "table.layer_height.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docFastPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_fastPrintSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.fast_segment_speed.format").String(), true
+	} else if docFastPrintSpeed < 10 || docFastPrintSpeed > 1000 {
+		curErr, hasErr = lang.Call("getString", "error.fast_segment_speed.small_or_big").String(), true
+	} else {
+		fastPrintSpeed = docFastPrintSpeed
+	}
+	setErrorDescription(doc, lang, "table.fast_segment_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docSlowPrintSpeed, err := parseInputToInt(doc.Call("getElementById", "k3d_la_slowPrintSpeed").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.slow_segment_speed.format").String(), true
+	} else if docSlowPrintSpeed < 10 || docSlowPrintSpeed > 1000 {
+		curErr, hasErr = lang.Call("getString", "error.slow_segment_speed.small_or_big").String(), true
+	} else {
+		slowPrintSpeed = docSlowPrintSpeed
+	}
+	setErrorDescription(doc, lang, "table.slow_segment_speed.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	// Параметры калибровки
+
+	minK, maxK := kFactorLimits()
+	kRangeStr := fmt.Sprintf(lang.Call("getString", "error.k_factor.range").String(), fmt.Sprint(minK), fmt.Sprint(maxK))
+
+	// initial K, end K and number of segments aren't used by explicit list, so they aren't checked
+	kSchedule = parseKSchedule(doc.Call("getElementById", "k3d_la_kSchedule").Get("value").String())
+	listMode := kSchedule == kScheduleList
+
+	docInitKFactor, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_initKFactor").Get("value").String())
+	if !listMode && err != nil {
+		curErr, hasErr = lang.Call("getString", "error.init_la.format").String(), true
+	} else if !listMode && (docInitKFactor < minK || docInitKFactor > maxK) {
+		curErr, hasErr = lang.Call("getString", "error.init_la.small_or_big").String()+kRangeStr, true
+	} else {
+		initKFactor = docInitKFactor
+	}
+	setErrorDescription(doc, lang, "table.init_la.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docEndKFactor, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_endKFactor").Get("value").String())
+	if !listMode && err != nil {
+		curErr, hasErr = lang.Call("getString", "error.end_la.format").String(), true
+	} else if !listMode && (docEndKFactor < minK || docEndKFactor > maxK) {
+		curErr, hasErr = lang.Call("getString", "error.end_la.small_or_big").String()+kRangeStr, true
+	} else {
+		endKFactor = docEndKFactor
+	}
+	setErrorDescription(doc, lang, "table.end_la.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docNumSegment, err := parseInputToInt(doc.Call("getElementById", "k3d_la_numSegments").Get("value").String())
+	if !listMode && err != nil {
+		curErr, hasErr = lang.Call("getString", "error.num_segments.format").String(), true
+	} else if !listMode && (docNumSegment < 2 || docNumSegment > 100) {
+		curErr, hasErr = lang.Call("getString", "error.num_segments.small_or_big").String(), true
+	} else {
+		numSegments = docNumSegment
+	}
+	setErrorDescription(doc, lang, "table.num_segments.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docSegmentHeight, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_segmentHeight").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.segment_height.format").String(), true
+	} else if docSegmentHeight < 0.5 || docSegmentHeight > 10.0 {
+		curErr, hasErr = lang.Call("getString", "error.segment_height.small_or_big").String(), true
+	} else {
+		segmentHeight = docSegmentHeight
+	}
+	setErrorDescription(doc, lang, "table.segment_height.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	continuousK = doc.Call("getElementById", "k3d_la_continuousK").Get("checked").Bool()
+
+	// explicit list sets K and number of segments, other schedules go from initial to end K
+	docKList, err := parseInputToFloatList(doc.Call("getElementById", "k3d_la_kList").Get("value").String())
+	if listMode && err != nil {
+		curErr, hasErr = lang.Call("getString", "error.k_list.format").String(), true
+	} else if listMode && (len(docKList) < 2 || len(docKList) > 100) {
+		curErr, hasErr = lang.Call("getString", "error.k_list.small_or_big").String(), true
+	} else if listMode {
+		for _, k := range docKList {
+			if k < minK || k > maxK {
+				curErr, hasErr = lang.Call("getString", "error.k_list.out_of_range").String()+kRangeStr, true
+			}
+		}
+		if !hasErr {
+			numSegments = len(docKList)
+			kFactors = docKList
+		}
+	} else {
+		kFactors = scheduleKFactors()
+	}
+	setErrorDescription(doc, lang, "table.k_list.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	docSmoothTime, err := parseInputToFloat(doc.Call("getElementById", "k3d_la_smoothTime").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.smooth_time.format").String(), true
+	} else if docSmoothTime < 0.005 || docSmoothTime > 0.2 {
+		curErr, hasErr = lang.Call("getString", "error.smooth_time.small_or_big").String(), true
+	} else {
+		smoothTime = docSmoothTime
+	}
+	setErrorDescription(doc, lang, "table.smooth_time.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	restoreMode = parseRestoreMode(doc.Call("getElementById", "k3d_la_restoreMode").Get("value").String())
+
+	docNormalKFactor := strings.TrimSpace(doc.Call("getElementById", "k3d_la_normalKFactor").Get("value").String())
+	hasNormalK = false
+	if docNormalKFactor != "" {
+		docNormalK, err := parseInputToFloat(docNormalKFactor)
+		if err != nil {
+			curErr, hasErr = lang.Call("getString", "error.normal_k.format").String(), true
+		} else if docNormalK < minK || docNormalK > maxK {
+			curErr, hasErr = lang.Call("getString", "error.normal_k.small_or_big").String()+kRangeStr, true
+		} else {
+			normalKFactor, hasNormalK = docNormalK, true
+		}
+	} else if firmware == firmwareMarlin && marlinLAVersion == marlinLA15Slot {
+		// K of the user stays in the first slot, restoring just switches back to it
+	} else if restoreMode == restoreNormal || (restoreMode == restoreSaved && firmware != firmwareKlipper && firmware != firmwareRRF) {
+		// firmware can't remember K, so it must be set by user
+		curErr, hasErr = lang.Call("getString", "error.normal_k.not_set").String(), true
+	}
+	setErrorDescription(doc, lang, "table.normal_k.description", curErr, hasErr, allowModify)
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	startGcode = doc.Call("getElementById", "k3d_la_startGcode").Get("value").String()
+	endGcode = doc.Call("getElementById", "k3d_la_endGcode").Get("value").String()
+
+	// warnings don't prevent generation, they are only shown to user
+	startWarnings := lintGcode(lang, startGcode, true)
+	endWarnings := lintGcode(lang, endGcode, false)
+	setWarningDescription(doc, lang, "table.start_gcode.description", startWarnings, allowModify)
+	setWarningDescription(doc, lang, "table.end_gcode.description", endWarnings, allowModify)
+	generationWarnings = append(startWarnings, endWarnings...)
+	generationWarnings = append(generationWarnings, wipeWarnings...)
+
+	modelWidth = 40.0
+
+	docMaxFlow, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_maxFlow").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.max_flow.format").String(), true
+	} else if docMaxFlow != 0 && (docMaxFlow < 1 || docMaxFlow > 200) {
+		curErr, hasErr = lang.Call("getString", "error.max_flow.small_or_big").String(), true
+	} else {
+		maxFlow = docMaxFlow
+	}
+	setErrorDescription(doc, lang, "table.max_flow.description", curErr, hasErr, allowModify)
+	maxFlowValid := !hasErr
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	// hotend can't melt more plastic, than maxFlow, so the tower would show underextrusion instead of LA
+	flowLimitCap = doc.Call("getElementById", "k3d_la_flowLimit").Get("value").String() == "cap"
+	flowWarnings := make([]string, 0)
+	if maxFlow > 0 && maxFlowValid {
+		speeds := []*int{&fastPrintSpeed, &slowPrintSpeed, &firstLayerPrintSpeed}
+		widths := []float64{lineWidth, lineWidth, math.Max(firstLayerLineWidth, raftLineWidth(firstLayerLineWidth, modelWidth+10.0))}
+		keys := []string{"warning.flow.fast", "warning.flow.slow", "warning.flow.first_layer"}
+		for n := range speeds {
+			capped := capSpeed(widths[n], *speeds[n])
+			if capped == *speeds[n] {
+				continue
+			}
+			if flowLimitCap {
+				flowWarnings = append(flowWarnings, fmt.Sprintf(lang.Call("getString", keys[n]+"_capped").String(), capped))
+				*speeds[n] = capped
+			} else {
+				flowWarnings = append(flowWarnings, fmt.Sprintf(lang.Call("getString", keys[n]).String(), fmt.Sprint(roundFloat(lineFlow(widths[n], *speeds[n]), 1))))
+			}
+		}
+	}
+	setWarningDescription(doc, lang, "table.max_flow.description", flowWarnings, allowModify && maxFlowValid)
+	generationWarnings = append(generationWarnings, flowWarnings...)
+
+	// fast sections are short, with low acceleration printer doesn't reach fast speed before slowing down again
+	growTower = doc.Call("getElementById", "k3d_la_growTower").Get("checked").Bool()
+	if growTower {
+		modelWidth = math.Max(modelWidth, math.Min(requiredModelWidth(), maxModelWidth()))
+	}
+
+	// small layers don't have time to cool down, so tower deforms and it looks like LA artifacts
+	layerTimeMode = parseLayerTimeMode(doc.Call("getElementById", "k3d_la_layerTimeMode").Get("value").String())
+	docMinLayerTime, err := parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_minLayerTime").Get("value").String())
+	if err != nil {
+		curErr, hasErr = lang.Call("getString", "error.min_layer_time.format").String(), true
+	} else if docMinLayerTime < 0 || docMinLayerTime > 120 {
+		curErr, hasErr = lang.Call("getString", "error.min_layer_time.small_or_big").String(), true
+	} else if docMinLayerTime != 0 && layerTimeMode == layerTimeTower && bedY/2-15.0 < (modelWidth+10.0)/2+5.0+coolingTowerSize {
+		curErr, hasErr = lang.Call("getString", "error.min_layer_time.no_room").String(), true
+	} else {
+		minLayerTime = docMinLayerTime
+	}
+	setErrorDescription(doc, lang, "table.min_layer_time.description", curErr, hasErr, allowModify)
+	minLayerTimeValid := !hasErr
+	if hasErr {
+		errorString = errorString + curErr + "\n"
+		hasErr = false
+		retErr = true
+	}
+
+	layerTimeWarnings := make([]string, 0)
+	if minLayerTime > 0 && minLayerTimeValid {
+		if layerTimeMode == layerTimeSlow && estimatedLayerTime() < minLayerTime {
+			// both speeds are reduced in the same ratio, so the test still compares the same speed transitions
+			factor := estimatedLayerTime() / minLayerTime
+			fastPrintSpeed = int(math.Max(1, math.Floor(float64(fastPrintSpeed)*factor)))
+			slowPrintSpeed = int(math.Max(1, math.Floor(float64(slowPrintSpeed)*factor)))
+			layerTimeWarnings = append(layerTimeWarnings, fmt.Sprintf(lang.Call("getString", "warning.min_layer_time.slowed").String(), fastPrintSpeed, slowPrintSpeed))
+		} else if layerTimeMode == layerTimeTower && coolingTowerLoops() == maxCoolingTowerLoops() && coolingTowerTime(coolingTowerLoops()) < minLayerTime-estimatedLayerTime() {
+			layerTimeWarnings = append(layerTimeWarnings, lang.Call("getString", "warning.min_layer_time.cooling_tower").String())
+		}
+	}
+	setWarningDescription(doc, lang, "table.min_layer_time.description", layerTimeWarnings, allowModify && minLayerTimeValid)
+	generationWarnings = append(generationWarnings, layerTimeWarnings...)
+
+	// peak speed is compared with fast speed after it's slowed down for minimum layer time
+	peakSpeed = reachedSpeed(fastSectionLength(modelWidth))
+	speedWarnings := make([]string, 0)
+	if peakSpeed < float64(fastPrintSpeed) {
+		speedWarnings = append(speedWarnings, fmt.Sprintf(lang.Call("getString", "warning.acceleration.peak_speed").String(), fmt.Sprint(roundFloat(peakSpeed, 0)), fastPrintSpeed))
+	}
+	setWarningDescription(doc, lang, "table.grow_tower.description", speedWarnings, allowModify)
+	generationWarnings = append(generationWarnings, speedWarnings...)
+
+	if !showErrorBox {
+		return !retErr
+	}
+
+	// end check of parameters
+	if !retErr {
+		println("OK")
+		return true
+	} else {
+		println(errorString)
+		js.Global().Call("showError", errorString)
+		return false
+	}
+}
+
+// lintGcode looks for common problems in user start or end G-code and tries to guess its firmware
+func lintGcode(lang js.Value, text string, isStart bool) []string {
+	warnings := make([]string, 0)
+	hasHoming, hasHeatWait, hasStartMacro := false, false, false
+	firmwareHints := make([]int, len(firmwareNames))
+	foreign := make([]string, 0)
+	unknownPlaceholders := make([]string, 0)
+
+	for _, line := range strings.Split(text, "\n") {
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, word := range strings.FieldsFunc(line, isNotPlaceholderRune) {
+			if strings.HasPrefix(word, "$") && !containsString(knownPlaceholders, word) && !containsString(unknownPlaceholders, word) {
+				unknownPlaceholders = append(unknownPlaceholders, word)
+			}
+		}
+
+		command := strings.ToUpper(fields[0])
+		if command == "G28" {
+			hasHoming = true
+		} else if command == "M109" || command == "M190" || command == "M116" || command == "TEMPERATURE_WAIT" {
+			hasHeatWait = true
+		} else if command == "M500" || command == "M502" || command == "SAVE_CONFIG" {
+			warnings = append(warnings, fmt.Sprintf(lang.Call("getString", "warning.gcode.state_change").String(), command))
+		} else if command == "M83" && isStart && !relativeE {
+			warnings = append(warnings, lang.Call("getString", "warning.gcode.relative_extrusion").String())
+		} else if command == "PRINT_START" || command == "START_PRINT" {
+			// start macro is expected to home and heat the printer
+			hasStartMacro = true
+		}
+
+		// find commands, that exist only in one firmware. Smoothieware sets pressure advance with RRF's M572 too
+		gcodeFirmware := -1
+		if strings.Contains(command, "_") && !strings.HasPrefix(command, "$") {
+			gcodeFirmware = firmwareKlipper
+		} else if fields[0] == "if" || fields[0] == "elif" || fields[0] == "while" || fields[0] == "var" || fields[0] == "global" || fields[0] == "set" || fields[0] == "echo" ||
+			command == "M98" || command == "M116" || command == "M557" || (command == "M572" && firmware != firmwareSmoothieware) {
+			gcodeFirmware = firmwareRRF
+		} else if command == "M900" {
+			gcodeFirmware = firmwareMarlin
+		} else if command == "M233" || command == "M232" || command == "M320" {
+			gcodeFirmware = firmwareRepetier
+		} else if command == "M375" {
+			gcodeFirmware = firmwareSmoothieware
+		}
+		if gcodeFirmware >= 0 {
+			firmwareHints[gcodeFirmware]++
+			if gcodeFirmware != firmware && !containsString(foreign, fields[0]) {
+				foreign = append(foreign, fields[0])
+			}
+		}
+	}
+
+	if isStart && !hasHoming && !hasStartMacro {
+		warnings = append(warnings, lang.Call("getString", "warning.gcode.no_homing").String())
+	}
+	if isStart && !hasHeatWait && !hasStartMacro {
+		warnings = append(warnings, lang.Call("getString", "warning.gcode.no_heat_wait").String())
+	}
+	if len(unknownPlaceholders) > 0 {
+		warnings = append(warnings, fmt.Sprintf(lang.Call("getString", "warning.gcode.unknown_placeholders").String(), strings.Join(unknownPlaceholders, ", ")))
+	}
+	if len(foreign) > 0 {
+		warnings = append(warnings, fmt.Sprintf(lang.Call("getString", "warning.gcode.foreign_commands").String(), firmwareNames[firmware], strings.Join(foreign, ", ")))
+
+		// suggest firmware with the most specific commands
+		likely := 0
+		for f := range firmwareHints {
+			if firmwareHints[f] > firmwareHints[likely] {
+				likely = f
+			}
+		}
+		if likely != firmware && firmwareHints[likely] > 0 {
+			warnings = append(warnings, fmt.Sprintf(lang.Call("getString", "warning.gcode.likely_firmware").String(), firmwareNames[likely]))
+		}
+	}
+	return warnings
+}
+
+// isNotPlaceholderRune splits G-code line into words, that can be placeholders like $BEDTEMP
+func isNotPlaceholderRune(r rune) bool {
+	return r != '$' && r != '_' && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+}
+
+func containsString(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+// generateWarningsInfo copies warnings about user G-code and print settings to the header
+func generateWarningsInfo() string {
+	info := ""
+	for _, warning := range generationWarnings {
+		info += ";Warning: " + warning + "\n"
+	}
+	return info
+}
+
+func write(str ...string) {
+	if dryRun {
+		return
+	}
+	for i := 0; i < len(str); i++ {
+		js.Global().Call("writeToFile", str[i])
+	}
+}
+
+func checkSegments(this js.Value, i []js.Value) interface{} {
+	if check(false, false) {
+		lang := js.Global().Get("lang")
+
+		// generate calibration parameters
+		caliParams := generateSegmentTable(lang)
+
+		collectStatistics()
+		caliParams += fmt.Sprintf(lang.Call("getString", "generator.flows").String(), fmt.Sprint(roundFloat(lineFlow(lineWidth, fastPrintSpeed), 1)), fmt.Sprint(roundFloat(lineFlow(lineWidth, slowPrintSpeed), 1)),
+			fmt.Sprint(roundFloat(lineFlow(raftLineWidth(firstLayerLineWidth, modelWidth+10.0), firstLayerPrintSpeed), 1)), fmt.Sprint(roundFloat(lineFlow(firstLayerLineWidth, firstLayerPrintSpeed), 1))) + "\n"
+		caliParams += fmt.Sprintf(lang.Call("getString", "generator.peak_speed").String(), fmt.Sprint(roundFloat(peakSpeed, 0)), fmt.Sprint(roundFloat(modelWidth, 1))) + "\n"
+		caliParams += fmt.Sprintf(lang.Call("getString", "generator.layer_time").String(), fmt.Sprint(roundFloat(estimatedLayerTime(), 1))) + "\n"
+		caliParams += generateFilamentUsage(lang, extrudedVolume) + "\n"
+		caliParams += fmt.Sprintf(lang.Call("getString", "generator.travel_distance").String(), fmt.Sprint(roundFloat(travelDistance/1000, 2)))
+
+		js.Global().Call("setSegmentsPreview", caliParams)
+	} else {
+		js.Global().Call("setSegmentsPreview", js.ValueOf(nil))
+		check(false, true)
+	}
+	return js.ValueOf(nil)
+}
+
+// setDefaultKRange puts the typical K range of the selected firmware into the form
+func setDefaultKRange(this js.Value, i []js.Value) interface{} {
+	doc := js.Global().Get("document")
+	docFirmware, ok := selectedFirmware(doc)
+	if !ok {
+		return js.ValueOf(nil)
+	}
+	firmware = docFirmware
+	marlinLAVersion = parseMarlinLAVersion(doc.Call("getElementById", "k3d_la_marlinLA").Get("value").String())
+	printerESteps, _ = parseOptionalInputToFloat(doc.Call("getElementById", "k3d_la_printerESteps").Get("value").String())
+
+	initK, endK := defaultKRange()
+	doc.Call("getElementById", "k3d_la_initKFactor").Set("value", fmt.Sprint(initK))
+	doc.Call("getElementById", "k3d_la_endKFactor").Set("value", fmt.Sprint(endK))
+	return js.ValueOf(nil)
+}
+
+// defaultKRange returns the typical K range of the selected firmware and Marlin LA version, it fits into kFactorLimits
+func defaultKRange() (float64, float64) {
+	minK, maxK := kFactorLimits()
+	if firmware == firmwareMarlin && marlinLAVersion == marlinLA10 {
+		return minK, math.Min(100.0, maxK)
+	} else if firmware == firmwareRepetier && printerESteps > 0 {
+		// Repetier advance L is counted in E steps, so it is pressure advance time multiplied by E steps per mm
+		return minK, math.Min(roundFloat(0.2*printerESteps, 0), maxK)
+	} else if firmware == firmwareRepetier {
+		// Repetier advance L is usually tens
+		return minK, math.Min(100.0, maxK)
+	}
+	return minK, math.Min(0.2, maxK)
+}
+
+// selectedFirmware returns firmware checked in the form
+func selectedFirmware(doc js.Value) (int, bool) {
+	for f, id := range firmwareRadios {
+		if doc.Call("getElementById", id).Get("checked").Bool() {
+			return f, true
+		}
+	}
+	return firmwareMarlin, false
+}
+
+// setFilamentDefaults puts density, temperatures and K range of the selected filament type into the form
+func setFilamentDefaults(this js.Value, i []js.Value) interface{} {
+	doc := js.Global().Get("document")
+	profile, ok := filamentProfiles[doc.Call("getElementById", "k3d_la_filamentType").Get("value").String()]
+	if !ok {
+		// custom filament keeps values of user
+		return js.ValueOf(nil)
+	}
+	doc.Call("getElementById", "k3d_la_filamentDensity").Set("value", fmt.Sprint(profile.Density))
+	doc.Call("getElementById", "k3d_la_hotendTemperature").Set("value", fmt.Sprint(profile.HotendTemperature))
+	doc.Call("getElementById", "k3d_la_bedTemperature").Set("value", fmt.Sprint(profile.BedTemperature))
+
+	firmware, _ = selectedFirmware(doc)
+	marlinLAVersion = parseMarlinLAVersion(doc.Call("getElementById", "k3d_la_marlinLA").Get("value").String())
+	initK, endK := defaultKRange()
+	doc.Call("getElementById", "k3d_la_initKFactor").Set("value", fmt.Sprint(initK))
+	doc.Call("getElementById", "k3d_la_endKFactor").Set("value", fmt.Sprint(roundFloat(endK*profile.KScale, 3)))
+	return js.ValueOf(nil)
+}
+
+// applyPrinterInfo parses printer answers collected by readPrinter() and puts them into the form
+func applyPrinterInfo(this js.Value, i []js.Value) interface{} {
+	doc := js.Global().Get("document")
+	lang := js.Global().Get("lang")
+	if len(i) == 0 {
+		return js.ValueOf(nil)
+	}
+	info := parsePrinterInfo(i[0].String())
+
+	report := make([]string, 0)
+	if !info.HasFirmware {
+		doc.Call("getElementById", "table.printer.description").Set("innerHTML", lang.Call("getString", "table.printer.description").String()+
+			"<br><span class=\"inline-error\">"+lang.Call("getString", "printer.info.not_detected").String()+"</span>")
+		return js.ValueOf(nil)
+	}
+
+	for f, id := range firmwareRadios {
+		doc.Call("getElementById", id).Set("checked", f == info.Firmware)
+	}
+	report = append(report, fmt.Sprintf(lang.Call("getString", "printer.info.firmware").String(), info.FirmwareName))
+
+	if info.HasBedSize {
+		doc.Call("getElementById", "k3d_la_bedX").Set("value", fmt.Sprint(roundFloat(info.BedX, 1)))
+		doc.Call("getElementById", "k3d_la_bedY").Set("value", fmt.Sprint(roundFloat(info.BedY, 1)))
+		report = append(report, fmt.Sprintf(lang.Call("getString", "printer.info.bed").String(), fmt.Sprint(roundFloat(info.BedX, 1)), fmt.Sprint(roundFloat(info.BedY, 1))))
+	}
+	if info.HasExtruders && info.NumTools > 1 {
+		doc.Call("getElementById", "k3d_la_numTools").Set("value", fmt.Sprint(info.NumTools))
+		report = append(report, fmt.Sprintf(lang.Call("getString", "printer.info.extruders").String(), info.NumTools))
+	}
+	if info.HasESteps {
+		doc.Call("getElementById", "k3d_la_printerESteps").Set("value", fmt.Sprint(roundFloat(info.ESteps, 2)))
+		report = append(report, fmt.Sprintf(lang.Call("getString", "printer.info.e_steps").String(), fmt.Sprint(roundFloat(info.ESteps, 2))))
+		if info.Firmware == firmwareRepetier {
+			setDefaultKRange(js.Null(), nil)
+		}
+	}
+	if info.HasKFactor {
+		if info.Firmware == firmwareMarlin {
+			marlinLA := "1.5"
+			if info.KFactor > 2 {
+				// LA 1.5 uses K from 0 to 2, LA 1.0 - in tens to hundreds
+				marlinLA = "1.0"
+			}
+			doc.Call("getElementById", "k3d_la_marlinLA").Set("value", marlinLA)
+			setDefaultKRange(js.Null(), nil)
+		}
+		doc.Call("getElementById", "k3d_la_normalKFactor").Set("value", fmt.Sprint(info.KFactor))
+		report = append(report, fmt.Sprintf(lang.Call("getString", "printer.info.k_factor").String(), fmt.Sprint(info.KFactor)))
 	}
+	doc.Call("getElementById", "k3d_la_printerLinAdvance").Set("value", fmt.Sprint(info.LinAdvance))
+	if info.LinAdvance == linAdvanceDisabled {
+		report = append(report, "<span class=\"inline-error\">"+lang.Call("getString", "printer.info.no_linear_advance").String()+"</span>")
+	}
+
+	js.Global().Call("saveForm")
+	check(false, true)
+	doc.Call("getElementById", "table.printer.description").Set("innerHTML", lang.Call("getString", "table.printer.description").String()+"<br>"+strings.Join(report, "<br>"))
 	return js.ValueOf(nil)
 }
 
+// parsePrinterInfo reads answers of different firmwares, unknown commands are simply ignored by printers
+func parsePrinterInfo(text string) PrinterInfo {
+	var info PrinterInfo
+	for _, line := range strings.Split(text, "\n") {
+		// Marlin prefixes answers with "echo:", Klipper - with "// "
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "// "))
+		for strings.HasPrefix(line, "echo:") {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "echo:"))
+		}
+		// comments of M503 output
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		if idx := strings.Index(line, "FIRMWARE_NAME:"); idx >= 0 {
+			name := strings.TrimSpace(line[idx+len("FIRMWARE_NAME:"):])
+			for _, key := range []string{" SOURCE_CODE_URL:", " PROTOCOL_VERSION:", " FIRMWARE_VERSION:", " MACHINE_TYPE:", " EXTRUDER_COUNT:", " ELECTRONICS:", " FIRMWARE_DATE:"} {
+				if end := strings.Index(name, key); end >= 0 {
+					name = strings.TrimSpace(name[:end])
+				}
+			}
+			info.FirmwareName = strings.TrimRight(name, ",")
+			lowerName := strings.ToLower(line)
+			info.HasFirmware = true
+			if strings.Contains(lowerName, "klipper") {
+				info.Firmware = firmwareKlipper
+			} else if strings.Contains(lowerName, "reprapfirmware") {
+				info.Firmware = firmwareRRF
+			} else if strings.Contains(lowerName, "repetier") {
+				info.Firmware = firmwareRepetier
+			} else if strings.Contains(lowerName, "smoothie") {
+				info.Firmware = firmwareSmoothieware
+			} else {
+				// Marlin and its forks like Prusa firmware
+				info.Firmware = firmwareMarlin
+			}
+		}
+		if idx := strings.Index(line, "EXTRUDER_COUNT:"); idx >= 0 {
+			fields := strings.Fields(line[idx+len("EXTRUDER_COUNT:"):])
+			if len(fields) > 0 {
+				if count, err := strconv.Atoi(fields[0]); err == nil {
+					info.NumTools, info.HasExtruders = count, true
+				}
+			}
+		}
+
+		if strings.HasPrefix(line, "Unknown command") && strings.Contains(line, "M900") {
+			info.LinAdvance = linAdvanceDisabled
+		} else if strings.HasPrefix(line, "M900") || strings.HasPrefix(line, "Advance") {
+			// "M900 K0.22" from M503 or "Advance K=0.22" from M900
+			for _, word := range strings.Fields(strings.ReplaceAll(line, "=", "")) {
+				if k, err := strconv.ParseFloat(strings.TrimPrefix(word, "K"), 64); err == nil && strings.HasPrefix(word, "K") {
+					info.KFactor, info.HasKFactor = k, true
+					info.LinAdvance = linAdvanceEnabled
+					break
+				}
+			}
+		} else if strings.HasPrefix(line, "Extruder pressure advance:") {
+			// RRF answer to M572, first extruder is used
+			fields := strings.FieldsFunc(line[len("Extruder pressure advance:"):], func(r rune) bool { return r == ',' || r == ' ' })
+			if len(fields) > 0 {
+				if k, err := strconv.ParseFloat(fields[0], 64); err == nil {
+					info.KFactor, info.HasKFactor = k, true
+				}
+			}
+		} else if strings.HasPrefix(line, "pressure_advance:") {
+			// Klipper answer to SET_PRESSURE_ADVANCE without parameters, it reports the active extruder
+			if k, err := strconv.ParseFloat(strings.TrimSpace(line[len("pressure_advance:"):]), 64); err == nil {
+				info.KFactor, info.HasKFactor = k, true
+			}
+		} else if strings.HasPrefix(line, "M92 ") {
+			// Marlin steps per unit from M503
+			for _, word := range strings.Fields(line) {
+				if e, err := strconv.ParseFloat(strings.TrimPrefix(word, "E"), 64); err == nil && strings.HasPrefix(word, "E") {
+					info.ESteps, info.HasESteps = e, true
+				}
+			}
+		} else if strings.HasPrefix(line, "EPR:") && strings.HasSuffix(line, "Extr.1 steps per mm") {
+			// Repetier EEPROM from M205: "EPR:3 207 96.0000 Extr.1 steps per mm"
+			fields := strings.Fields(line)
+			if len(fields) > 3 {
+				if e, err := strconv.ParseFloat(fields[2], 64); err == nil {
+					info.ESteps, info.HasESteps = e, true
+				}
+			}
+		} else if idx := strings.Index(line, "Max:"); idx >= 0 && strings.Contains(line, "Min:") {
+			// M211 soft endstops: "Min: X0.00 Y0.00 Z0.00 Max: X235.00 Y235.00 Z250.00". Printers don't report bed size,
+			// so maximums are only an approximation of it
+			for _, word := range strings.Fields(line[idx+len("Max:"):]) {
+				if v, err := strconv.ParseFloat(word[1:], 64); err == nil && strings.HasPrefix(word, "X") {
+					info.BedX = v
+				} else if err == nil && strings.HasPrefix(word, "Y") {
+					info.BedY = v
+				}
+			}
+			info.HasBedSize = info.BedX > 0 && info.BedY > 0
+		}
+	}
+	if info.Firmware != firmwareMarlin {
+		// only Marlin can be built without linear advance
+		info.LinAdvance = linAdvanceUnknown
+	}
+	return info
+}
+
 func checkJs(this js.Value, i []js.Value) interface{} {
 	check(false, true)
 	return js.ValueOf(nil)
@@ -466,105 +1716,245 @@ func generate(this js.Value, i []js.Value) interface{} {
 
 	// generate calibration parameters
 	lang := js.Global().Get("lang")
-	segmentStr := lang.Call("getString", "generator.segment").String()
 
 	caliParams := ""
-	deltaKFactor := math.Abs((endKFactor - initKFactor) / float64(numSegments-1))
-	maxKFactor := math.Max(initKFactor, endKFactor)
-	minKFactor := math.Min(initKFactor, endKFactor)
-	currentKFactor := minKFactor
 	caliParams += "; ====================\n; Поддержите выход новых калибраторов, инструкций и видео!\n;https://donate.stream/dmitrysorkin\n; ====================\n"
-	for i := 0; i < numSegments; i++ {
-		caliParams += fmt.Sprintf(segmentStr, numSegments-i, fmt.Sprint(roundFloat(maxKFactor-deltaKFactor*float64(i), 3)))
-	}
+	caliParams += generateSegmentTable(lang)
+
+	collectStatistics()
 
-	fileName := fmt.Sprintf("K3D_LA_H%d-B%d_%s-%s_d%s.gcode", hotendTemperature, bedTemperature, fmt.Sprint(roundFloat(initKFactor, 2)), fmt.Sprint(roundFloat(endKFactor, 2)), fmt.Sprint(roundFloat(deltaKFactor, 3)))
+	// file name shows K of the bottom and the top segments in printed order
+	fileName := fmt.Sprintf("K3D_LA_H%d-B%d_%s-%s_%s.gcode", towerHotendTemperature, towerBedTemperature, fmt.Sprint(roundFloat(kFactors[0], 2)), fmt.Sprint(roundFloat(kFactors[numSegments-1], 2)), kScheduleName())
 	js.Global().Call("beginSaveFile", fileName)
 
 	// gcode initialization
 	write("; generated by K3D LA calibration ", js.Global().Get("calibrator_version").String(), "\n",
 		"; Written by Dmitry Sorkin @ http://k3d.tech/, Kekht and YTKAB0BP\n",
 		fmt.Sprintf(";Bedsize: %s:%s [mm]\n", fmt.Sprint(roundFloat(bedX, 1)), fmt.Sprint(roundFloat(bedY, 1))),
-		fmt.Sprintf(";Firmware (0-Marlin, 1-Klipper, 2-RRF): %d\n", firmware),
+		fmt.Sprintf(";Firmware (0-Marlin, 1-Klipper, 2-RRF, 3-Repetier, 4-Smoothieware): %d\n", firmware),
+		generateLANote(),
 		fmt.Sprintf(";Z-offset: %s [mm]\n", fmt.Sprint(roundFloat(zOffset, 3))),
+		fmt.Sprintf(";Z-offset mode (0-firmware offset, 1-home offset, 2-baked into Z, 3-G92): %d\n", effectiveZOffsetMode()),
 		fmt.Sprintf(";Delta: %s\n", strconv.FormatBool(delta)),
-		fmt.Sprintf(";G29: %s\n", strconv.FormatBool(bedProbe)),
+		fmt.Sprintf(";Bed probe (0-none, 1-full, 2-print area, 3-print area M555, 4-load mesh): %d\n", probeMode),
 		fmt.Sprintf(";Temp: %d/%d [°C]\n", hotendTemperature, bedTemperature),
+		fmt.Sprintf(";Tower temp: %d/%d [°C], wait: %s\n", towerHotendTemperature, towerBedTemperature, strconv.FormatBool(tempWait)),
+		fmt.Sprintf(";Heat soak: %s [min], chamber temp: %d [°C]\n", fmt.Sprint(roundFloat(soakTime, 1)), chamberTemperature),
+		generateToolsInfo(),
 		fmt.Sprintf(";Flow: %d\n", flow),
+		fmt.Sprintf(";Extrusion model (0-rectangle, 1-rounded): %d\n", extrusionModel),
+		fmt.Sprintf(";Flow in E: %s\n", strconv.FormatBool(flowInE)),
+		generateFilamentInfo(),
 		fmt.Sprintf(";Fan: %s\n", fmt.Sprint(roundFloat(float64(cooling)/2.55, 1))),
+		fmt.Sprintf(";First layer fan: %s, ramp layers: %d, fan: %s, off at end: %s\n", fmt.Sprint(roundFloat(float64(firstLayerFan)/2.55, 1)), fanRampLayers, partFan, strconv.FormatBool(fanOff)),
+		fmt.Sprintf(";Auxiliary fan: %s, speed: %s\n", auxFan, fmt.Sprint(roundFloat(float64(auxFanSpeed)/2.55, 1))),
 		fmt.Sprintf(";Line width: %s [mm]\n", fmt.Sprint(roundFloat(lineWidth, 2))),
 		fmt.Sprintf(";First layer line width: %s [mm]\n", fmt.Sprint(roundFloat(lineWidth, 2))),
 		fmt.Sprintf(";Layer height: %s [mm]\n", fmt.Sprint(roundFloat(layerHeight, 2))),
 		fmt.Sprintf(";Fast print speed: %d [mm/s]\n", fastPrintSpeed),
 		fmt.Sprintf(";Slow print speed: %d [mm/s]\n", slowPrintSpeed),
 		fmt.Sprintf(";First layer print speed: %d [mm/s]\n", firstLayerPrintSpeed),
+		generateFlowInfo(),
+		fmt.Sprintf(";Peak speed of fast sections: %s [mm/s]\n", fmt.Sprint(roundFloat(peakSpeed, 0))),
+		fmt.Sprintf(";Model width: %s [mm]\n", fmt.Sprint(roundFloat(modelWidth, 1))),
+		fmt.Sprintf(";Layer time: %s [s], min: %s [s], mode (0-slow down, 1-dwell, 2-cooling tower): %d\n", fmt.Sprint(roundFloat(estimatedLayerTime(), 1)), fmt.Sprint(roundFloat(minLayerTime, 1)), layerTimeMode),
 		fmt.Sprintf(";Travel speed: %d [mm/s]\n", travelSpeed),
+		fmt.Sprintf(";Z travel speed: %d [mm/s]\n", zTravelSpeed),
+		fmt.Sprintf(";Acceleration: %s [mm/s^2]\n", fmt.Sprint(roundFloat(acceleration, 0))),
+		fmt.Sprintf(";Jerk/SCV: %s [mm/s]\n", fmt.Sprint(roundFloat(jerk, 2))),
+		fmt.Sprintf(";Junction deviation: %s [mm]\n", fmt.Sprint(roundFloat(junctionDeviation, 3))),
+		fmt.Sprintf(";Max velocity: %s [mm/s]\n", fmt.Sprint(roundFloat(maxVelocity, 0))),
+		generateMotionInfo(),
+		fmt.Sprintf(";Retraction: %s [mm], extra restart: %s [mm], speed: %d/%d [mm/s]\n", fmt.Sprint(roundFloat(retractLength, 2)), fmt.Sprint(roundFloat(retractExtra, 2)), retractSpeed, unretractSpeed),
+		fmt.Sprintf(";Z-hop: %s [mm], mode (0-normal, 1-ramp, 2-spiral): %d\n", fmt.Sprint(roundFloat(zHop, 2)), zHopMode),
+		fmt.Sprintf(";Min travel for retraction: %s [mm]\n", fmt.Sprint(roundFloat(minTravel, 2))),
+		fmt.Sprintf(";Coast: %s [mm], wipe: %s [mm]\n", fmt.Sprint(roundFloat(coastDistance, 2)), fmt.Sprint(roundFloat(wipeDistance, 2))),
+		fmt.Sprintf(";Avoid crossing printed walls: %s\n", strconv.FormatBool(avoidCrossing)),
+		fmt.Sprintf(";Travel distance: %s [m]\n", fmt.Sprint(roundFloat(travelDistance/1000, 2))),
+		fmt.Sprintf(";Firmware retraction: %s\n", strconv.FormatBool(firmwareRetraction)),
+		fmt.Sprintf(";Relative extrusion: %s\n", strconv.FormatBool(relativeE)),
+		fmt.Sprintf(";E reset (0-never, 1-every layer, 2-every segment): %d\n", eResetMode),
+		fmt.Sprintf(";Volumetric extrusion: %s\n", strconv.FormatBool(volumetricE)),
 		fmt.Sprintf(";Segment height: %s [mm]\n", fmt.Sprint(roundFloat(segmentHeight, 2))),
+		fmt.Sprintf(";Continuous K: %s\n", strconv.FormatBool(continuousK)),
+		fmt.Sprintf(";K schedule (0-linear, 1-logarithmic, 2-list): %d\n", kSchedule),
+		generateRestoreInfo(),
+		generateWarningsInfo(),
 		caliParams)
 
-	var g29str string
-	if bedProbe {
-		g29str = "G29"
-	} else {
-		g29str = ""
-	}
-	replacer := strings.NewReplacer("$BEDTEMP", strconv.Itoa(bedTemperature), "$HOTTEMP", strconv.Itoa(hotendTemperature), "$G29", g29str, "$FLOW", strconv.Itoa(flow))
-	write(replacer.Replace(startGcode), "\n")
+	generateBody()
 
-	write("M82\n", "M106 S0\n")
+	// write calibration parameters to resultContainer
+	js.Global().Call("showError", caliParams)
+
+	// save file
+	js.Global().Call("finishFile")
+
+	return js.ValueOf(nil)
+}
+
+// generateBody writes everything after the header: start gcode, purge, rafts, towers and end gcode
+func generateBody() {
+	currentKFactor := kFactors[0]
+
+	// raft adjusts first layer line width, it is returned back for the next run
+	savedFirstLayerLineWidth := firstLayerLineWidth
+	defer func() { firstLayerLineWidth = savedFirstLayerLineWidth }()
+	retracted = false
+	printedAreas = make([]Rect, 0)
 
-	// generate first layer
 	var bedCenter Point
 	if delta {
 		bedCenter.X, bedCenter.Y, bedCenter.Z = 0, 0, layerHeight
 	} else {
 		bedCenter.X, bedCenter.Y, bedCenter.Z = bedX/2, bedY/2, layerHeight
 	}
+
+	// purge lines go along whole bed, rafts and towers are above them. When only print area is probed,
+	// purge lines are shortened to the row of towers, so the first layer doesn't leave the mesh
+	purgeMinX, purgeMaxX := bedCenter.X-bedX/2+15.0, bedCenter.X+bedX/2-15.0
+	if probeMode == probeArea || probeMode == probePrusa {
+		rowHalfWidth := float64(len(calibratedTools())-1)/2*towerSpacing() + (modelWidth+10.0)/2
+		purgeMinX, purgeMaxX = bedCenter.X-rowHalfWidth, bedCenter.X+rowHalfWidth
+	}
+	var areaMin, areaMax Point
+	areaMin.X, areaMin.Y = purgeMinX, bedCenter.Y-modelWidth-10.0
+	areaMax.X, areaMax.Y = purgeMaxX, bedCenter.Y+(modelWidth+10.0)/2
+	if minLayerTime > 0 && layerTimeMode == layerTimeTower {
+		areaMax.Y = coolingTowerCenter(bedCenter).Y + coolingTowerSize/2
+	}
+	g29str := generateProbeCommand(areaMin, areaMax)
+	if restoreMode == restoreSaved && firmware == firmwareKlipper {
+		// save state before start gcode changes flow and positioning modes
+		write("SAVE_GCODE_STATE NAME=K3D_LA\n")
+	}
+	// flow baked into E must not be applied second time by M221
+	flowStr := strconv.Itoa(flow)
+	if flowInE {
+		flowStr = "100"
+	}
+	replacer := strings.NewReplacer("$BEDTEMP", strconv.Itoa(bedTemperature), "$HOTTEMP", strconv.Itoa(hotendTemperature), "$G29", g29str, "$FLOW", flowStr,
+		"$TOWERHOTTEMP", strconv.Itoa(towerHotendTemperature), "$TOWERBEDTEMP", strconv.Itoa(towerBedTemperature),
+		"$CHAMBERTEMP", strconv.Itoa(chamberTemperature), "$SOAKTIME", fmt.Sprint(roundFloat(soakTime*60, 0)))
+	write(replacer.Replace(startGcode), "\n")
+	write(generateHeatSoak()...)
+
+	write(generateExtrusionMode(relativeE), generateFanCommand(partFan, firstLayerFan))
+	if auxFan != "" {
+		write(generateFanCommand(auxFan, auxFanSpeed))
+	}
+	write(generateVolumetricSetup(false)...)
+	if restoreMode == restoreSaved {
+		write(generatePASave()...)
+	}
+	write(generateMotionSetup()...)
+	write(generateRetractionSetup()...)
+
+	// select tools to calibrate
+	tools := calibratedTools()
+	useToolChanges := multiTool || tool != 0
+	currentTool = 0
+	primedTools = make([]bool, maxTools)
+	if useToolChanges {
+		// heat up all tools at once, tool changes will wait for them
+		for _, t := range tools {
+			write(fmt.Sprintf("M104 T%d S%d\n", t, toolTemperature(t)))
+		}
+	}
+	if !multiTool && tool != currentTool {
+		// start gcode heated the default tool, that doesn't print the tower
+		write(fmt.Sprintf("M104 T%d S0\n", currentTool))
+	}
+
+	// generate first layer
 	currentE = 0
 	currentSpeed = firstLayerPrintSpeed
 	currentCoordinates.X, currentCoordinates.Y, currentCoordinates.Z = 0, 0, 0
 
-	// move to layer height to avoid nozzle striking at bed
-	write(fmt.Sprintf("G1 Z%s\n", fmt.Sprint(roundFloat(layerHeight+zOffset, 2))))
+	// apply Z-offset and move to layer height to avoid nozzle striking at bed
+	write(generateZOffsetCommand(false))
+	if effectiveZOffsetMode() == zOffsetG92 {
+		write(fmt.Sprintf("G1 Z%s F%d\n", fmt.Sprint(roundFloat(layerHeight+zOffset, 2)), zTravelSpeed*60))
 
-	// make printer think, that he is on layerHeight
-	write(fmt.Sprintf("G92 Z%s\n", fmt.Sprint(roundFloat(layerHeight, 2))))
+		// make printer think, that he is on layerHeight
+		write(fmt.Sprintf("G92 Z%s\n", fmt.Sprint(roundFloat(layerHeight, 2))))
+	} else {
+		write(fmt.Sprintf("G1 Z%s F%d\n", fmt.Sprint(roundFloat(layerHeight+bakedZOffset(), 2)), zTravelSpeed*60))
+	}
 	currentCoordinates.Z = layerHeight
 
-	// purge nozzle
-	modelWidth := 40.0
-	var purgeStart Point
-	purgeStart.X, purgeStart.Y, purgeStart.Z = bedCenter.X-bedX/2+15.0, bedCenter.Y-modelWidth-10.0, currentCoordinates.Z
-	purgeTwo := purgeStart
-	purgeTwo.X = bedCenter.X + bedX/2 - 15.0
-	purgeThree := purgeTwo
-	purgeThree.Y += firstLayerLineWidth
-	purgeEnd := purgeThree
-	purgeEnd.X = purgeStart.X
+	// place towers of all tools in a row along X
+	towerCenters := make([]Point, len(tools))
+	for k := range tools {
+		towerCenters[k] = bedCenter
+		towerCenters[k].X += (float64(k) - float64(len(tools)-1)/2) * towerSpacing()
+	}
+
+	// generate raft trajectory, it adjusts first layer line width to the raft, so purge keeps the original one
+	purgeLineWidth := firstLayerLineWidth
+	raftTrajectory := generateZigZagTrajectory(bedCenter, firstLayerLineWidth, modelWidth+10.0)
+
+	for k, t := range tools {
+		if useToolChanges {
+			write(generateToolChange(t)...)
+		}
+
+		// purge nozzle, every tool gets its own pair of lines
+		var purgeStart Point
+		purgeStart.X, purgeStart.Y, purgeStart.Z = purgeMinX, bedCenter.Y-modelWidth-10.0+float64(k)*(purgeLineWidth*2+1.0), currentCoordinates.Z
+		purgeTwo := purgeStart
+		purgeTwo.X = purgeMaxX
+		purgeThree := purgeTwo
+		purgeThree.Y += purgeLineWidth
+		purgeEnd := purgeThree
+		purgeEnd.X = purgeStart.X
+
+		// move to start of purge, other tools have already printed their lines and rafts
+		if k == 0 {
+			write(generateMove(currentCoordinates, purgeStart, 0.0, travelSpeed)...)
+		} else {
+			write(generateTravel(purgeStart)...)
+		}
 
-	// move to start of purge
-	write(generateMove(currentCoordinates, purgeStart, 0.0, travelSpeed)...)
+		// add purge to gcode
+		write(generateMove(currentCoordinates, purgeTwo, purgeLineWidth, firstLayerPrintSpeed)...)
+		write(generateMove(currentCoordinates, purgeThree, purgeLineWidth, firstLayerPrintSpeed)...)
+		write(generateMove(currentCoordinates, purgeEnd, purgeLineWidth, firstLayerPrintSpeed)...)
+		purgeMin, purgeMax := purgeStart, purgeThree
+		purgeMin.Y -= purgeLineWidth / 2
+		purgeMax.Y += purgeLineWidth / 2
+		markPrinted(purgeMin, purgeMax)
+
+		// shift raft trajectory under the tower of this tool
+		trajectory := make([]Point, len(raftTrajectory))
+		for i := range raftTrajectory {
+			trajectory[i] = raftTrajectory[i]
+			trajectory[i].X += towerCenters[k].X - bedCenter.X
+		}
 
-	// add purge to gcode
-	write(generateMove(currentCoordinates, purgeTwo, firstLayerLineWidth, firstLayerPrintSpeed)...)
-	write(generateMove(currentCoordinates, purgeThree, firstLayerLineWidth, firstLayerPrintSpeed)...)
-	write(generateMove(currentCoordinates, purgeEnd, firstLayerLineWidth, firstLayerPrintSpeed)...)
+		// move to start of raft
+		write(generateTravel(trajectory[0])...)
 
-	// generate raft trajectory
-	trajectory := generateZigZagTrajectory(bedCenter, firstLayerLineWidth, modelWidth+10.0)
+		// print raft
+		for i := 1; i < len(trajectory); i++ {
+			write(generateMove(currentCoordinates, trajectory[i], firstLayerLineWidth, firstLayerPrintSpeed)...)
+		}
+		raftMin, raftMax := towerCenters[k], towerCenters[k]
+		raftMin.X, raftMin.Y = raftMin.X-(modelWidth+10.0)/2, raftMin.Y-(modelWidth+10.0)/2
+		raftMax.X, raftMax.Y = raftMax.X+(modelWidth+10.0)/2, raftMax.Y+(modelWidth+10.0)/2
+		markPrinted(raftMin, raftMax)
 
-	// move to start of raft
-	write(generateRetraction())
-	write(generateMove(currentCoordinates, trajectory[0], 0.0, travelSpeed)...)
-	write(generateDeretraction())
+		// set LA for first segment
+		write(generateLACommand(t, currentKFactor))
+	}
 
-	// print raft
-	for i := 1; i < len(trajectory); i++ {
-		write(generateMove(currentCoordinates, trajectory[i], firstLayerLineWidth, firstLayerPrintSpeed)...)
+	// cooling tower stands on the bed, so it starts from the first layer
+	if minLayerTime > 0 && layerTimeMode == layerTimeTower {
+		write(generateCoolingTower(coolingTowerCenter(bedCenter), firstLayerPrintSpeed)...)
 	}
 
-	// set LA for first segment
-	write(generateLACommand(currentKFactor))
+	// switch to tower temperatures after the raft
+	write(generateTowerTemperatures(tools, useToolChanges)...)
 
 	// generate model
 	layersPerSegment := int(segmentHeight / layerHeight)
@@ -573,85 +1963,815 @@ func generate(this js.Value, i []js.Value) interface{} {
 		write(fmt.Sprintf(";layer #%s\n", fmt.Sprint(roundFloat(currentCoordinates.Z/layerHeight, 0))))
 
 		// change fan speed
-		if i < 4 {
-			write(fmt.Sprintf("M106 S%s\n", fmt.Sprint(roundFloat(float64(cooling*i/3), 0))))
+		if fanSpeed(i) != fanSpeed(i-1) {
+			write(generateFanCommand(partFan, fanSpeed(i)))
 		}
 
 		// modify print settings if switching segments
 		addition := 0.0
 		if i%layersPerSegment == 0 {
-			currentKFactor += deltaKFactor
-			write(generateLACommand(currentKFactor))
+			currentKFactor = kFactors[i/layersPerSegment]
 			addition = lineWidth / 2
 		} else {
-			addition = 0
+			addition = 0
+		}
+		if continuousK {
+			currentKFactor = continuousKFactor(float64(i))
+		}
+		layerZ := currentCoordinates.Z + layerHeight
+
+		// reset extruder position to keep precision of E values
+		if eResetMode == eResetLayer || (eResetMode == eResetSegment && i%layersPerSegment == 0) {
+			write(generateEReset()...)
+		}
+
+		for k, t := range tools {
+			if useToolChanges {
+				write(generateToolChange(t)...)
+			}
+			if i%layersPerSegment == 0 || continuousK {
+				write(generateLACommand(t, currentKFactor))
+			}
+
+			// move to start of new layer
+			layerStart := towerCenters[k]
+			if i%layersPerSegment == 0 {
+				layerStart.Y += (modelWidth - lineWidth/2) / 2
+			} else {
+				layerStart.Y += (modelWidth - lineWidth) / 2
+			}
+			layerStart.Z = layerZ
+			write(generateTravel(layerStart)...)
+			// generate layer gcode
+			for j := 0; j < numPerimeters; j++ {
+				// calc lines parameters
+				currentModelWidth := modelWidth + addition - lineWidth*2*float64(j+1)
+				rightLongLine := (currentModelWidth - rightShortLine) / 2
+				frontLongLine := (currentModelWidth - frontShortLine) / 2
+				leftLongLine := (currentModelWidth - leftShortLine) / 2
+				// print back line's right part
+				write(generateRelativeMove(currentModelWidth/2, 0, 0, lineWidth, fastPrintSpeed)...)
+				// print right line
+				write(generateRelativeMove(0, -rightLongLine, 0, lineWidth, fastPrintSpeed)...)
+				write(generateRelativeMove(0, -rightShortLine, 0, lineWidth, slowPrintSpeed)...)
+				write(generateRelativeMove(0, -rightLongLine, 0, lineWidth, fastPrintSpeed)...)
+				// print front line
+				write(generateRelativeMove(-frontLongLine, 0, 0, lineWidth, fastPrintSpeed)...)
+				write(generateRelativeMove(-frontShortLine, 0, 0, lineWidth, slowPrintSpeed)...)
+				write(generateRelativeMove(-frontLongLine, 0, 0, lineWidth, fastPrintSpeed)...)
+				// print left line
+				write(generateRelativeMove(0, leftLongLine, 0, lineWidth, fastPrintSpeed)...)
+				write(generateRelativeMove(0, leftShortLine, 0, lineWidth, slowPrintSpeed)...)
+				write(generateRelativeMove(0, leftLongLine, 0, lineWidth, fastPrintSpeed)...)
+				// print back line left part, extrusion stops before the seam when coasting
+				write(generateRelativeMove(currentModelWidth/2-coastDistance, 0, 0, lineWidth, fastPrintSpeed)...)
+				if coastDistance > 0 {
+					write(generateRelativeMove(coastDistance, 0, 0, 0.0, fastPrintSpeed)...)
+					// coasting is a part of the perimeter, not a travel
+					travelDistance -= coastDistance
+				}
+				// wipe the seam before travel to the next layer
+				if j == numPerimeters-1 && wipeDistance > 0 {
+					write(generateWipe(-1, 0)...)
+				}
+				// move to start of next perimeter if it exists
+				if j != numPerimeters-1 {
+					nextPerimeter := currentCoordinates
+					nextPerimeter.Y -= lineWidth
+					write(generateTravel(nextPerimeter)...)
+				}
+			}
+			towerMin, towerMax := towerCenters[k], towerCenters[k]
+			towerMin.X, towerMin.Y = towerMin.X-(modelWidth+addition)/2, towerMin.Y-(modelWidth+addition)/2
+			towerMax.X, towerMax.Y = towerMax.X+(modelWidth+addition)/2, towerMax.Y+(modelWidth+addition)/2
+			markPrinted(towerMin, towerMax)
+		}
+
+		// give small layer time to cool down before the next one
+		write(generateLayerCooling(bedCenter)...)
+	}
+
+	// turn off heaters of all tools, end gcode cools only the active one
+	if multiTool {
+		for _, t := range tools {
+			write(fmt.Sprintf("M104 T%d S0\n", t))
+		}
+	}
+	// Klipper only waits for the chamber, it doesn't heat it
+	if chamberTemperature > 0 && firmware != firmwareKlipper {
+		write("M141 S0\n")
+	}
+
+	// restore Z-offset, nozzle is lifted first because babystepping moves it
+	if zOffset != 0 && effectiveZOffsetMode() != zOffsetBake {
+		liftPoint := currentCoordinates
+		liftPoint.Z += 1.0
+		write(generateMove(currentCoordinates, liftPoint, 0.0, travelSpeed)...)
+		if effectiveZOffsetMode() == zOffsetG92 {
+			write(fmt.Sprintf("G92 Z%s\n", fmt.Sprint(roundFloat(currentCoordinates.Z+zOffset, 2))))
+		} else {
+			write(generateZOffsetCommand(true))
+		}
+	}
+
+	// revert motion limits
+	write(generateMotionRestore()...)
+
+	// restore pressure advance and flow. Klipper restores gcode state with Z-offset, so it goes after Z-offset restoring
+	if restoreMode != restoreNone {
+		write(generatePARestore()...)
+	} else {
+		// second K slot is used only by the test, so printer goes back to the first one anyway
+		write(generateLASlotRestore()...)
+	}
+
+	// return to linear extrusion
+	write(generateVolumetricSetup(true)...)
+
+	// set extrusion mode expected by the end gcode
+	if extrusionAfter != extrusionAfterNone {
+		write(generateExtrusionMode(extrusionAfter == extrusionAfterRelative))
+	}
+
+	// switch fans off, so they don't run after print if end gcode doesn't do it
+	if fanOff {
+		write(generateFanCommand(partFan, 0))
+		if auxFan != "" {
+			write(generateFanCommand(auxFan, 0))
+		}
+	}
+
+	// end gcode
+	write(endGcode)
+}
+
+// collectStatistics runs generator without output, so usage estimates are known before the header is written
+func collectStatistics() {
+	extrudedVolume, travelDistance = 0, 0
+	dryRun = true
+	generateBody()
+	dryRun = false
+}
+
+// continuousKFactor returns K of tower layer. K changes linearly between K of segments from the first tower layer to the last one, layer can be fractional.
+func continuousKFactor(layer float64) float64 {
+	lastLayer := float64(numSegments*int(segmentHeight/layerHeight) - 1)
+	position := math.Min(math.Max((layer-1)/math.Max(1, lastLayer-1), 0), 1) * float64(numSegments-1)
+	segment := int(math.Min(math.Floor(position), float64(numSegments-2)))
+	return kFactors[segment] + (kFactors[segment+1]-kFactors[segment])*(position-float64(segment))
+}
+
+// scheduleKFactors returns K of segments from bottom to top. Logarithmic schedule has small steps at low K and big ones at high K.
+func scheduleKFactors() []float64 {
+	factors := make([]float64, numSegments)
+	low, high := math.Min(initKFactor, endKFactor), math.Max(initKFactor, endKFactor)
+	// geometric progression is shifted by a tenth of the range, so it can start from zero K
+	offset := (high - low) / 10
+	for s := range factors {
+		t := float64(s) / float64(numSegments-1)
+		if kSchedule == kScheduleLog && high > low {
+			factors[s] = low - offset + offset*math.Pow((high-low+offset)/offset, t)
+		} else {
+			factors[s] = low + (high-low)*t
+		}
+	}
+
+	// tower goes from initial K at the bottom to end K at the top
+	if initKFactor > endKFactor {
+		for s := 0; s < numSegments/2; s++ {
+			factors[s], factors[numSegments-1-s] = factors[numSegments-1-s], factors[s]
+		}
+	}
+	return factors
+}
+
+// kScheduleName describes K schedule in file name, linear one is described by its step
+func kScheduleName() string {
+	if kSchedule == kScheduleLog {
+		return "log"
+	} else if kSchedule == kScheduleList {
+		return "list"
+	}
+	return "d" + fmt.Sprint(roundFloat(math.Abs(kFactors[1]-kFactors[0]), 3))
+}
+
+// generateSegmentTable lists K of segments from top to bottom, or K of tower heights in continuous mode
+func generateSegmentTable(lang js.Value) string {
+	if continuousK {
+		return generateHeightTable(lang.Call("getString", "generator.height_k").String())
+	}
+	segmentStr := lang.Call("getString", "generator.segment").String()
+	table := ""
+	for s := numSegments - 1; s >= 0; s-- {
+		table += fmt.Sprintf(segmentStr, s+1, fmt.Sprint(roundFloat(kFactors[s], 3)))
+	}
+	return table
+}
+
+// generateHeightTable lists K for every millimeter of the tower from top to bottom, so K can be found by measured height
+func generateHeightTable(str string) string {
+	// layer i is printed at height (i+1)*layerHeight, the raft is layer 0
+	bottom := layerHeight * 2
+	top := layerHeight * float64(numSegments*int(segmentHeight/layerHeight))
+	table := ""
+	for height := math.Floor(top); height >= bottom; height-- {
+		table += fmt.Sprintf(str, fmt.Sprint(roundFloat(height, 1)), fmt.Sprint(roundFloat(continuousKFactor(height/layerHeight-1), 4)))
+	}
+	return table
+}
+
+// kFactorPrecision returns number of K decimals in LA commands, continuous K changes by small steps
+func kFactorPrecision() uint {
+	if continuousK {
+		return 4
+	}
+	return 3
+}
+
+func generateLACommand(t int, kFactor float64) string {
+	// old single extruder form is kept when the default tool is calibrated
+	explicitTool := multiTool || t != 0
+	if firmware == firmwareMarlin {
+		toolStr := ""
+		if explicitTool {
+			toolStr = fmt.Sprintf("T%d ", t)
+		}
+		if marlinLAVersion == marlinLA15Slot {
+			// select second K slot and set its value, slot 0 stays untouched
+			return fmt.Sprintf("M900 %sS1 L%s\n", toolStr, fmt.Sprint(roundFloat(kFactor, kFactorPrecision())))
+		}
+		return fmt.Sprintf("M900 %sK%s\n", toolStr, fmt.Sprint(roundFloat(kFactor, kFactorPrecision())))
+	} else if firmware == firmwareKlipper {
+		extruderStr := ""
+		if explicitTool {
+			extruderStr = "EXTRUDER=" + klipperExtruderName(t) + " "
+		}
+		return fmt.Sprintf("SET_PRESSURE_ADVANCE %sADVANCE=%s SMOOTH_TIME=%s\n", extruderStr, fmt.Sprint(roundFloat(kFactor, kFactorPrecision())), fmt.Sprint(roundFloat(smoothTime, 3)))
+	} else if firmware == firmwareRRF {
+		return fmt.Sprintf("M572 D%d S%s\n", t, fmt.Sprint(roundFloat(kFactor, kFactorPrecision())))
+	} else if firmware == firmwareRepetier {
+		// quadratic advance is switched off, so only linear advance L is calibrated
+		return fmt.Sprintf("M233 X0 Y%s\n", fmt.Sprint(roundFloat(kFactor, kFactorPrecision())))
+	} else if firmware == firmwareSmoothieware {
+		return fmt.Sprintf("M572 S%s\n", fmt.Sprint(roundFloat(kFactor, kFactorPrecision())))
+	}
+
+	return ";no firmware information\n"
+}
+
+// klipperExtruderName returns the name of the extruder config section used by tool t
+func klipperExtruderName(t int) string {
+	if t == 0 {
+		return "extruder"
+	}
+	return fmt.Sprintf("extruder%d", t)
+}
+
+// toolTowerTemperature returns the hotend temperature of tool t for the tower, tower temperature shifts temperatures of all tools
+func toolTowerTemperature(t int) int {
+	return toolTemperature(t) + towerHotendTemperature - hotendTemperature
+}
+
+// generateTowerTemperatures switches bed and hotends from first layer to tower temperatures. All heaters are started before waiting.
+func generateTowerTemperatures(tools []int, useToolChanges bool) []string {
+	cmds := make([]string, 0)
+	if towerBedTemperature != bedTemperature {
+		cmds = append(cmds, fmt.Sprintf("M140 S%d\n", towerBedTemperature))
+	}
+	if towerHotendTemperature != hotendTemperature {
+		for _, t := range tools {
+			if useToolChanges {
+				cmds = append(cmds, fmt.Sprintf("M104 T%d S%d\n", t, toolTowerTemperature(t)))
+			} else {
+				cmds = append(cmds, fmt.Sprintf("M104 S%d\n", toolTowerTemperature(t)))
+			}
+		}
+	}
+	if !tempWait {
+		return cmds
+	}
+
+	if towerBedTemperature != bedTemperature {
+		cmds = append(cmds, fmt.Sprintf("M190 S%d\n", towerBedTemperature))
+	}
+	if towerHotendTemperature != hotendTemperature {
+		for _, t := range tools {
+			if useToolChanges {
+				cmds = append(cmds, fmt.Sprintf("M109 T%d S%d\n", t, toolTowerTemperature(t)))
+			} else {
+				cmds = append(cmds, fmt.Sprintf("M109 S%d\n", toolTowerTemperature(t)))
+			}
+		}
+	}
+	return cmds
+}
+
+// generateHeatSoak waits until bed heats the printer and its chamber after start gcode
+func generateHeatSoak() []string {
+	cmds := make([]string, 0)
+	if soakTime > 0 {
+		cmds = append(cmds, fmt.Sprintf("M190 S%d\n", bedTemperature), fmt.Sprintf("G4 P%d\n", int(math.Round(soakTime*60*1000))))
+	}
+	if chamberTemperature == 0 {
+		return cmds
+	}
+
+	if firmware == firmwareKlipper {
+		// chamber of Klipper printer is usually heated by the bed, so there is only a sensor to wait for
+		return append(cmds, fmt.Sprintf("TEMPERATURE_WAIT SENSOR=\"%s\" MINIMUM=%d\n", chamberSensor, chamberTemperature))
+	} else if chamberWait {
+		return append(cmds, fmt.Sprintf("M191 S%d\n", chamberTemperature))
+	}
+	return append(cmds, fmt.Sprintf("M141 S%d\n", chamberTemperature))
+}
+
+// toolTemperature returns the hotend temperature of tool t, tools without their own temperature use the common one.
+// A single tool always uses the common temperature, that start gcode heats it to.
+func toolTemperature(t int) int {
+	if multiTool && t < len(toolTemperatures) {
+		return toolTemperatures[t]
+	}
+	return hotendTemperature
+}
+
+// generateToolsInfo describes calibrated tools in the header
+func generateToolsInfo() string {
+	if !multiTool {
+		return fmt.Sprintf(";Tool: %d\n", tool)
+	}
+
+	info := fmt.Sprintf(";Tools: %d, towers from left to right\n", numTools)
+	for t := 0; t < numTools; t++ {
+		info += fmt.Sprintf(";Tool %d temp: %d [°C]\n", t, toolTemperature(t))
+	}
+	return info
+}
+
+// generateToolChange activates tool t. Previous tool is retracted and new one waits for its temperature on the first use.
+// Extruder position is reset after each change, so the new tool is left retracted if it was used before.
+func generateToolChange(t int) []string {
+	cmds := make([]string, 0, 4)
+	if t == currentTool && primedTools[t] {
+		return cmds
+	}
+
+	if primedTools[currentTool] && !retracted {
+		cmds = append(cmds, generateRetraction())
+	}
+	if t != currentTool {
+		cmds = append(cmds, fmt.Sprintf("T%d\n", t))
+	}
+	cmds = append(cmds, "G92 E0\n")
+	if primedTools[t] {
+		// tool was retracted when it was deactivated, firmware retraction doesn't change E position
+		currentE = retractE()
+		if firmwareRetraction {
+			currentE = 0
+		}
+		retracted = true
+	} else {
+		cmds = append(cmds, fmt.Sprintf("M109 T%d S%d\n", t, toolTemperature(t)))
+		currentE = 0
+		retracted = false
+		primedTools[t] = true
+	}
+	currentTool = t
+
+	return cmds
+}
+
+// generateProbeCommand returns bed probing command of the selected firmware for $G29 placeholder.
+// Partial probing is limited by the rectangle between areaMin and areaMax.
+func generateProbeCommand(areaMin, areaMax Point) string {
+	if probeMode == probeNone {
+		return ""
+	} else if probeMode == probeLoad {
+		if firmware == firmwareMarlin {
+			if meshName != "" {
+				return "M420 S1 L" + meshName
+			}
+			return "M420 S1"
+		} else if firmware == firmwareKlipper {
+			if meshName != "" {
+				return "BED_MESH_PROFILE LOAD=" + meshName
+			}
+			return "BED_MESH_PROFILE LOAD=default"
+		} else if firmware == firmwareRRF {
+			if meshName != "" {
+				return "G29 S1 P\"" + meshName + "\""
+			}
+			return "G29 S1"
+		} else if firmware == firmwareRepetier {
+			return "M320"
+		} else if firmware == firmwareSmoothieware {
+			return "M375"
+		}
+	} else if probeMode == probeArea || probeMode == probePrusa {
+		if firmware == firmwareMarlin && probeMode == probePrusa {
+			return fmt.Sprintf("M555 X%s Y%s W%s H%s\nG29", fmt.Sprint(roundFloat(areaMin.X, 1)), fmt.Sprint(roundFloat(areaMin.Y, 1)),
+				fmt.Sprint(roundFloat(areaMax.X-areaMin.X, 1)), fmt.Sprint(roundFloat(areaMax.Y-areaMin.Y, 1)))
+		} else if firmware == firmwareMarlin {
+			return fmt.Sprintf("G29 L%s R%s F%s B%s", fmt.Sprint(roundFloat(areaMin.X, 1)), fmt.Sprint(roundFloat(areaMax.X, 1)),
+				fmt.Sprint(roundFloat(areaMin.Y, 1)), fmt.Sprint(roundFloat(areaMax.Y, 1)))
+		} else if firmware == firmwareKlipper {
+			return fmt.Sprintf("BED_MESH_CALIBRATE MESH_MIN=%s,%s MESH_MAX=%s,%s", fmt.Sprint(roundFloat(areaMin.X, 1)), fmt.Sprint(roundFloat(areaMin.Y, 1)),
+				fmt.Sprint(roundFloat(areaMax.X, 1)), fmt.Sprint(roundFloat(areaMax.Y, 1)))
+		} else if firmware == firmwareRRF {
+			// mesh definition stays changed until M557 from config.g is executed again, the user is told about it in the form
+			return fmt.Sprintf("M557 X%s:%s Y%s:%s P5 ;mesh grid stays changed until config.g is run again\nG29 S0", fmt.Sprint(roundFloat(areaMin.X, 1)), fmt.Sprint(roundFloat(areaMax.X, 1)),
+				fmt.Sprint(roundFloat(areaMin.Y, 1)), fmt.Sprint(roundFloat(areaMax.Y, 1)))
+		}
+		// firmware can't limit probing area, so probe whole bed
+	}
+
+	if firmware == firmwareKlipper {
+		return "BED_MESH_CALIBRATE"
+	} else if firmware == firmwareRRF {
+		return "G29 S0"
+	} else if firmware == firmwareRepetier || firmware == firmwareSmoothieware {
+		return "G32"
+	}
+	return "G29"
+}
+
+// effectiveZOffsetMode returns the selected Z-offset strategy or baking of the offset if firmware doesn't support the strategy
+func effectiveZOffsetMode() int {
+	if zOffsetMode == zOffsetFirmware && (firmware == firmwareRepetier || firmware == firmwareSmoothieware) {
+		return zOffsetBake
+	} else if zOffsetMode == zOffsetHomeOffset && firmware == firmwareMarlin {
+		// M206 replaces home offset stored by the user instead of adding to it, babystep is relative
+		return zOffsetFirmware
+	} else if zOffsetMode == zOffsetHomeOffset && firmware != firmwareRRF && firmware != firmwareKlipper {
+		return zOffsetBake
+	}
+	return zOffsetMode
+}
+
+// bakedZOffset returns the offset that must be added to every Z coordinate
+func bakedZOffset() float64 {
+	if effectiveZOffsetMode() == zOffsetBake {
+		return zOffset
+	}
+	return 0.0
+}
+
+// generateZOffsetCommand shifts the nozzle up by zOffset using the selected strategy. Restoring returns the offset, that printer had before.
+func generateZOffsetCommand(restore bool) string {
+	if zOffset == 0 {
+		return ""
+	}
+
+	offset := zOffset
+	if restore {
+		offset = -zOffset
+	}
+	mode := effectiveZOffsetMode()
+	if mode == zOffsetFirmware {
+		if firmware == firmwareKlipper {
+			return fmt.Sprintf("SET_GCODE_OFFSET Z_ADJUST=%s\n", fmt.Sprint(roundFloat(offset, 3)))
+		} else if firmware == firmwareMarlin {
+			return fmt.Sprintf("M290 Z%s\n", fmt.Sprint(roundFloat(offset, 3)))
+		} else if firmware == firmwareRRF {
+			return fmt.Sprintf("M290 S%s\n", fmt.Sprint(roundFloat(offset, 3)))
+		}
+	} else if mode == zOffsetHomeOffset {
+		// home offsets are absolute, so the previous offset is saved and the test offset is added to it
+		if firmware == firmwareKlipper {
+			if restore {
+				return "RESTORE_GCODE_STATE NAME=K3D_LA_Z_OFFSET\n"
+			}
+			return fmt.Sprintf("SAVE_GCODE_STATE NAME=K3D_LA_Z_OFFSET\nSET_GCODE_OFFSET Z_ADJUST=%s\n", fmt.Sprint(roundFloat(offset, 3)))
+		} else if firmware == firmwareRRF {
+			if restore {
+				return "G10 L2 P1 Z{global.k3dLaZOffset}\n"
+			}
+			sign := "+"
+			if offset < 0 {
+				sign = "-"
+			}
+			return strings.Join(generateRRFGlobal("k3dLaZOffset", "move.axes[2].workplaceOffsets[0]"), "") +
+				fmt.Sprintf("G10 L2 P1 Z{global.k3dLaZOffset %s %s}\n", sign, fmt.Sprint(roundFloat(math.Abs(offset), 3)))
+		}
+	}
+	return ""
+}
+
+// calibratedTools returns list of tools, that print towers
+func calibratedTools() []int {
+	if !multiTool {
+		return []int{tool}
+	}
+
+	tools := make([]int, numTools)
+	for t := 0; t < numTools; t++ {
+		tools[t] = t
+	}
+	return tools
+}
+
+// fanSpeed returns part cooling fan speed of layer, it grows from first layer speed to cooling speed during ramp layers
+func fanSpeed(layer int) int {
+	if layer <= 0 {
+		return firstLayerFan
+	} else if layer >= fanRampLayers {
+		return cooling
+	}
+	return firstLayerFan + int(math.Round(float64((cooling-firstLayerFan)*layer)/float64(fanRampLayers)))
+}
+
+// isValidFan checks fan field. Klipper fans are set by names of config sections, other firmwares use fan numbers
+func isValidFan(fan string) bool {
+	if fan == "" {
+		return true
+	}
+	if firmware == firmwareKlipper {
+		return !strings.ContainsAny(fan, " \t=")
+	}
+	n, err := strconv.Atoi(fan)
+	return err == nil && n >= 0 && n <= 9
+}
+
+// generateFanCommand sets speed (0-255) of fan. Klipper M106 controls only [fan] section, other fans need SET_FAN_SPEED.
+func generateFanCommand(fan string, speed int) string {
+	if fan == "" {
+		return fmt.Sprintf("M106 S%d\n", speed)
+	}
+	if firmware == firmwareKlipper {
+		return fmt.Sprintf("SET_FAN_SPEED FAN=%s SPEED=%s\n", fan, fmt.Sprint(roundFloat(float64(speed)/255, 2)))
+	}
+	return fmt.Sprintf("M106 P%s S%d\n", fan, speed)
+}
+
+// generatePASave remembers current pressure advance of calibrated tools in firmware variables
+func generatePASave() []string {
+	cmds := make([]string, 0, 4)
+	for _, t := range calibratedTools() {
+		if firmware == firmwareKlipper {
+			cmds = append(cmds, "K3D_LA_SAVE_PA EXTRUDER="+klipperExtruderName(t)+"\n")
+		} else if firmware == firmwareRRF {
+			cmds = append(cmds, generateRRFGlobal(fmt.Sprintf("k3dLaPa%d", t), fmt.Sprintf("move.extruders[%d].pressureAdvance", t))...)
+		}
+	}
+	return cmds
+}
+
+// generatePARestore returns pressure advance of calibrated tools to saved or normal value and resets flow
+func generatePARestore() []string {
+	cmds := make([]string, 0, 4)
+	if restoreMode == restoreSaved && firmware == firmwareKlipper {
+		cmds = append(cmds, "K3D_LA_RESTORE_PA\n", "RESTORE_GCODE_STATE NAME=K3D_LA\n")
+	} else if restoreMode == restoreSaved && firmware == firmwareRRF {
+		for _, t := range calibratedTools() {
+			cmds = append(cmds, fmt.Sprintf("M572 D%d S{global.k3dLaPa%d}\n", t, t))
+		}
+	} else if firmware == firmwareMarlin && marlinLAVersion == marlinLA15Slot {
+		cmds = append(cmds, generateLASlotRestore()...)
+	} else if firmware == firmwareRepetier || firmware == firmwareSmoothieware {
+		cmds = append(cmds, generateActiveToolCommands(func(t int) string { return generateLACommand(t, normalKFactor) })...)
+	} else {
+		for _, t := range calibratedTools() {
+			cmds = append(cmds, generateLACommand(t, normalKFactor))
+		}
+	}
+
+	if firmware != firmwareKlipper || restoreMode != restoreSaved {
+		cmds = append(cmds, generateFlowReset()...)
+	}
+	return cmds
+}
+
+// generateActiveToolCommands is for commands, that change only the active extruder. Each calibrated tool is selected
+// for its command and the current tool is selected back.
+func generateActiveToolCommands(command func(t int) string) []string {
+	if !multiTool {
+		return []string{command(tool)}
+	}
+	cmds := make([]string, 0, 2*numTools+1)
+	for _, t := range calibratedTools() {
+		cmds = append(cmds, fmt.Sprintf("T%d\n", t), command(t))
+	}
+	return append(cmds, fmt.Sprintf("T%d\n", currentTool))
+}
+
+// generateFlowReset sets flow of calibrated tools back to 100%
+func generateFlowReset() []string {
+	cmds := make([]string, 0, 4)
+	if firmware == firmwareRRF {
+		// M221 without D changes only the first extruder drive
+		for _, t := range calibratedTools() {
+			cmds = append(cmds, fmt.Sprintf("M221 D%d S100\n", t))
+		}
+	} else if firmware == firmwareMarlin && multiTool {
+		for _, t := range calibratedTools() {
+			cmds = append(cmds, fmt.Sprintf("M221 T%d S100\n", t))
+		}
+	} else if firmware == firmwareRepetier || firmware == firmwareSmoothieware {
+		// M221 changes flow of the active extruder only
+		cmds = append(cmds, generateActiveToolCommands(func(t int) string { return "M221 S100\n" })...)
+	} else {
+		cmds = append(cmds, "M221 S100\n")
+	}
+	return cmds
+}
+
+// generateLASlotRestore switches Marlin back to the first K slot, that keeps K of the user
+func generateLASlotRestore() []string {
+	cmds := make([]string, 0, 4)
+	if firmware != firmwareMarlin || marlinLAVersion != marlinLA15Slot {
+		return cmds
+	}
+	for _, t := range calibratedTools() {
+		if multiTool || t != 0 {
+			cmds = append(cmds, fmt.Sprintf("M900 T%d S0\n", t))
+		} else {
+			cmds = append(cmds, "M900 S0\n")
+		}
+	}
+	return cmds
+}
+
+// generateRestoreInfo describes in the header how printer state is restored. Klipper needs macros for that.
+func generateRestoreInfo() string {
+	if restoreMode == restoreNone {
+		return ";Restore PA: no\n"
+	} else if restoreMode == restoreSaved && firmware == firmwareKlipper {
+		return ";Restore PA: saved by macros, add them to printer.cfg:\n" + klipperRestoreMacros
+	} else if restoreMode == restoreSaved && firmware == firmwareRRF {
+		return ";Restore PA: saved in global variables, requires RRF 3.3+\n"
+	}
+	return fmt.Sprintf(";Restore PA: normal K-Factor %s\n", fmt.Sprint(roundFloat(normalKFactor, 3)))
+}
+
+// Klipper can read PA only in macros, so they remember it for every extruder in a variable
+const klipperRestoreMacros = `;[gcode_macro K3D_LA_SAVE_PA]
+;variable_pa: {}
+;gcode:
+;  {% set e = params.EXTRUDER|default(printer.toolhead.extruder) %}
+;  {% set pa = printer["gcode_macro K3D_LA_SAVE_PA"].pa %}
+;  {% set _ = pa.update({e: [printer[e].pressure_advance, printer[e].smooth_time]}) %}
+;  SET_GCODE_VARIABLE MACRO=K3D_LA_SAVE_PA VARIABLE=pa VALUE={pa|tojson|replace(' ', '')}
+;[gcode_macro K3D_LA_RESTORE_PA]
+;gcode:
+;  {% for e, v in printer["gcode_macro K3D_LA_SAVE_PA"].pa.items() %}
+;  SET_PRESSURE_ADVANCE EXTRUDER={e} ADVANCE={v[0]} SMOOTH_TIME={v[1]}
+;  {% endfor %}
+;  SET_GCODE_VARIABLE MACRO=K3D_LA_SAVE_PA VARIABLE=pa VALUE={}
+`
+
+// Klipper motion limits are remembered by macros too
+const klipperMotionMacros = `;[gcode_macro K3D_LA_SAVE_MOTION]
+;variable_limits: []
+;gcode:
+;  {% set t = printer.toolhead %}
+;  SET_GCODE_VARIABLE MACRO=K3D_LA_SAVE_MOTION VARIABLE=limits VALUE=[{t.max_velocity},{t.max_accel},{t.square_corner_velocity}]
+;[gcode_macro K3D_LA_RESTORE_MOTION]
+;gcode:
+;  {% set l = printer["gcode_macro K3D_LA_SAVE_MOTION"].limits %}
+;  SET_VELOCITY_LIMIT VELOCITY={l[0]} ACCEL={l[1]} SQUARE_CORNER_VELOCITY={l[2]}
+`
+
+// generateMotionSetup sets acceleration, jerk and speed limits for the test. Klipper and RRF remember previous values to revert them.
+func generateMotionSetup() []string {
+	cmds := make([]string, 0, 4)
+	if acceleration == 0 && jerk == 0 && junctionDeviation == 0 && maxVelocity == 0 {
+		return cmds
+	}
+
+	if firmware == firmwareKlipper {
+		cmds = append(cmds, "K3D_LA_SAVE_MOTION\n")
+		limits := ""
+		if maxVelocity != 0 {
+			limits += fmt.Sprintf(" VELOCITY=%s", fmt.Sprint(roundFloat(maxVelocity, 0)))
+		}
+		if acceleration != 0 {
+			limits += fmt.Sprintf(" ACCEL=%s", fmt.Sprint(roundFloat(acceleration, 0)))
+		}
+		if jerk != 0 {
+			limits += fmt.Sprintf(" SQUARE_CORNER_VELOCITY=%s", fmt.Sprint(roundFloat(jerk, 2)))
+		}
+		if limits != "" {
+			cmds = append(cmds, "SET_VELOCITY_LIMIT"+limits+"\n")
+		}
+		return cmds
+	}
+
+	if firmware == firmwareRRF {
+		cmds = append(cmds, generateRRFGlobal("k3dLaAccelP", "move.printingAcceleration")...)
+		cmds = append(cmds, generateRRFGlobal("k3dLaAccelT", "move.travelAcceleration")...)
+		cmds = append(cmds, generateRRFGlobal("k3dLaJerkX", "move.axes[0].jerk")...)
+		cmds = append(cmds, generateRRFGlobal("k3dLaJerkY", "move.axes[1].jerk")...)
+		cmds = append(cmds, generateRRFGlobal("k3dLaSpeedX", "move.axes[0].speed")...)
+		cmds = append(cmds, generateRRFGlobal("k3dLaSpeedY", "move.axes[1].speed")...)
+	}
+
+	if acceleration != 0 {
+		if firmware == firmwareRepetier {
+			cmds = append(cmds, fmt.Sprintf("M201 X%s Y%s\n", fmt.Sprint(roundFloat(acceleration, 0)), fmt.Sprint(roundFloat(acceleration, 0))),
+				fmt.Sprintf("M202 X%s Y%s\n", fmt.Sprint(roundFloat(acceleration, 0)), fmt.Sprint(roundFloat(acceleration, 0))))
+		} else if firmware == firmwareSmoothieware {
+			cmds = append(cmds, fmt.Sprintf("M204 S%s\n", fmt.Sprint(roundFloat(acceleration, 0))))
+		} else {
+			cmds = append(cmds, fmt.Sprintf("M204 P%s T%s\n", fmt.Sprint(roundFloat(acceleration, 0)), fmt.Sprint(roundFloat(acceleration, 0))))
 		}
-
-		// move to start of new layer
-		layerStart := bedCenter
-		if i%layersPerSegment == 0 {
-			layerStart.Y += (modelWidth - lineWidth/2) / 2
+	}
+	if jerk != 0 {
+		if firmware == firmwareMarlin {
+			cmds = append(cmds, fmt.Sprintf("M205 X%s Y%s\n", fmt.Sprint(roundFloat(jerk, 2)), fmt.Sprint(roundFloat(jerk, 2))))
+		} else if firmware == firmwareRRF {
+			cmds = append(cmds, fmt.Sprintf("M566 X%s Y%s\n", fmt.Sprint(roundFloat(jerk*60, 0)), fmt.Sprint(roundFloat(jerk*60, 0))))
+		}
+	}
+	if junctionDeviation != 0 && (firmware == firmwareMarlin || firmware == firmwareSmoothieware) {
+		if firmware == firmwareMarlin {
+			cmds = append(cmds, fmt.Sprintf("M205 J%s\n", fmt.Sprint(roundFloat(junctionDeviation, 3))))
 		} else {
-			layerStart.Y += (modelWidth - lineWidth) / 2
-		}
-		layerStart.Z = currentCoordinates.Z + layerHeight
-		write(generateMove(currentCoordinates, layerStart, 0.0, travelSpeed)...)
-		currentCoordinates.Z = layerStart.Z
-		// generate layer gcode
-		for j := 0; j < numPerimeters; j++ {
-			// calc lines parameters
-			currentModelWidth := modelWidth + addition - lineWidth*2*float64(j+1)
-			rightShortLine := 20.0
-			rightLongLine := (currentModelWidth - rightShortLine) / 2
-			frontShortLine := 2.0
-			frontLongLine := (currentModelWidth - frontShortLine) / 2
-			leftShortLine := 0.2
-			leftLongLine := (currentModelWidth - leftShortLine) / 2
-			// print back line's right part
-			write(generateRelativeMove(currentModelWidth/2, 0, 0, lineWidth, fastPrintSpeed)...)
-			// print right line
-			write(generateRelativeMove(0, -rightLongLine, 0, lineWidth, fastPrintSpeed)...)
-			write(generateRelativeMove(0, -rightShortLine, 0, lineWidth, slowPrintSpeed)...)
-			write(generateRelativeMove(0, -rightLongLine, 0, lineWidth, fastPrintSpeed)...)
-			// print front line
-			write(generateRelativeMove(-frontLongLine, 0, 0, lineWidth, fastPrintSpeed)...)
-			write(generateRelativeMove(-frontShortLine, 0, 0, lineWidth, slowPrintSpeed)...)
-			write(generateRelativeMove(-frontLongLine, 0, 0, lineWidth, fastPrintSpeed)...)
-			// print left line
-			write(generateRelativeMove(0, leftLongLine, 0, lineWidth, fastPrintSpeed)...)
-			write(generateRelativeMove(0, leftShortLine, 0, lineWidth, slowPrintSpeed)...)
-			write(generateRelativeMove(0, leftLongLine, 0, lineWidth, fastPrintSpeed)...)
-			// print back line left part
-			write(generateRelativeMove(currentModelWidth/2, 0, 0, lineWidth, fastPrintSpeed)...)
-			// move to start of next perimeter if it exists
-			if j != numPerimeters-1 {
-				write(generateRelativeMove(0, -lineWidth, 0, 0.0, fastPrintSpeed)...)
-			}
+			cmds = append(cmds, fmt.Sprintf("M205 X%s\n", fmt.Sprint(roundFloat(junctionDeviation, 3))))
 		}
 	}
+	if maxVelocity != 0 {
+		if firmware == firmwareRRF {
+			cmds = append(cmds, fmt.Sprintf("M203 X%s Y%s\n", fmt.Sprint(roundFloat(maxVelocity*60, 0)), fmt.Sprint(roundFloat(maxVelocity*60, 0))))
+		} else if firmware != firmwareRepetier {
+			cmds = append(cmds, fmt.Sprintf("M203 X%s Y%s\n", fmt.Sprint(roundFloat(maxVelocity, 0)), fmt.Sprint(roundFloat(maxVelocity, 0))))
+		}
+	}
+	return cmds
+}
 
-	// end gcode
-	write(endGcode)
+// generateMotionInfo describes in the header how motion limits are reverted
+func generateMotionInfo() string {
+	if acceleration == 0 && jerk == 0 && junctionDeviation == 0 && maxVelocity == 0 {
+		return ""
+	} else if firmware == firmwareKlipper {
+		return ";Motion limits are reverted by macros, add them to printer.cfg:\n" + klipperMotionMacros
+	} else if firmware == firmwareRRF {
+		return ";Motion limits are reverted from global variables, requires RRF 3.3+\n"
+	}
+	// firmware can't report its limits in G-code, so the whole EEPROM is loaded back
+	return ";Motion limits are reverted from EEPROM (M501), it also reverts all other unsaved settings\n"
+}
 
-	// write calibration parameters to resultContainer
-	js.Global().Call("showError", caliParams)
+// generateRRFGlobal stores value of object model expression in RRF global variable. Meta commands require RRF 3.3 or newer.
+func generateRRFGlobal(name, expression string) []string {
+	return []string{"if !exists(global." + name + ")\n",
+		"  global " + name + " = " + expression + "\n",
+		"else\n",
+		"  set global." + name + " = " + expression + "\n"}
+}
 
-	// save file
-	js.Global().Call("finishFile")
+// generateMotionRestore reverts limits changed by generateMotionSetup. Firmwares without variables reload them from EEPROM.
+func generateMotionRestore() []string {
+	cmds := make([]string, 0, 4)
+	if acceleration == 0 && jerk == 0 && junctionDeviation == 0 && maxVelocity == 0 {
+		return cmds
+	}
 
-	return js.ValueOf(nil)
+	if firmware == firmwareKlipper {
+		cmds = append(cmds, "K3D_LA_RESTORE_MOTION\n")
+	} else if firmware == firmwareRRF {
+		cmds = append(cmds, "M204 P{global.k3dLaAccelP} T{global.k3dLaAccelT}\n",
+			"M566 X{global.k3dLaJerkX} Y{global.k3dLaJerkY}\n",
+			"M203 X{global.k3dLaSpeedX} Y{global.k3dLaSpeedY}\n")
+	} else {
+		cmds = append(cmds, "M501\n")
+	}
+	return cmds
 }
 
-func generateLACommand(kFactor float64) string {
-	if firmware == 0 {
-		return fmt.Sprintf("M900 K%s\n", fmt.Sprint(roundFloat(kFactor, 3)))
-	} else if firmware == 1 {
-		return fmt.Sprintf("SET_PRESSURE_ADVANCE ADVANCE=%s SMOOTH_TIME=%s\n", fmt.Sprint(roundFloat(kFactor, 3)), fmt.Sprint(roundFloat(smoothTime, 3)))
-	} else if firmware == 2 {
-		return fmt.Sprintf("M572 D0 S%s\n", fmt.Sprint(roundFloat(kFactor, 3)))
+// generateLANote explains in the header what the K values of the tower mean for the selected firmware
+func generateLANote() string {
+	if firmware == firmwareMarlin {
+		if marlinLAVersion == marlinLA10 {
+			return ";K-Factor: Marlin linear advance 1.0 K (M900 K), typical values are tens to hundreds\n"
+		} else if marlinLAVersion == marlinLA15Slot {
+			return ";K-Factor: Marlin linear advance 1.5 K of the second slot (M900 S1 L), requires EXTRA_LIN_ADVANCE_K\n"
+		}
+		return ";K-Factor: Marlin linear advance 1.5 K (M900 K)\n"
+	} else if firmware == firmwareKlipper {
+		return ";K-Factor: Klipper pressure advance (SET_PRESSURE_ADVANCE ADVANCE), smooth time is fixed\n"
+	} else if firmware == firmwareRRF {
+		return ";K-Factor: RRF pressure advance of extruder drive 0 in seconds (M572 D0 S)\n"
+	} else if firmware == firmwareRepetier {
+		if printerESteps > 0 {
+			return fmt.Sprintf(";K-Factor: Repetier linear advance L (M233 Y), quadratic advance K is set to 0 (M233 X0). L / %s E steps is pressure advance in seconds\n", fmt.Sprint(printerESteps))
+		}
+		return ";K-Factor: Repetier linear advance L (M233 Y), quadratic advance K is set to 0 (M233 X0)\n"
+	} else if firmware == firmwareSmoothieware {
+		return ";K-Factor: Smoothieware pressure advance of the active extruder in seconds (M572 S)\n"
 	}
 
-	return ";no firmware information\n"
+	return ""
+}
+
+// kFactorLimits returns the allowed range of K values for the selected firmware
+func kFactorLimits() (float64, float64) {
+	if firmware == firmwareRepetier {
+		return 0.0, 200.0
+	} else if firmware == firmwareMarlin && marlinLAVersion == marlinLA10 {
+		return 0.0, 500.0
+	}
+
+	return 0.0, 2.0
 }
 
 func generateRelativeMove(x, y, z, width float64, speed int) []string {
@@ -663,6 +2783,25 @@ func generateRelativeMove(x, y, z, width float64, speed int) []string {
 }
 
 func generateMove(start, end Point, width float64, speed int) []string {
+	// Z axis is usually much slower, so travel with Z change is split: up before XY move and down after it
+	if width == 0 && end.Z != start.Z && (end.X != start.X || end.Y != start.Y) {
+		midPoint := start
+		if end.Z > start.Z {
+			midPoint.Z = end.Z
+		} else {
+			midPoint.X, midPoint.Y = end.X, end.Y
+		}
+		move := generateMove(start, midPoint, 0.0, speed)
+		return append(move, generateMove(midPoint, end, 0.0, speed)...)
+	}
+	if width == 0 && end.Z != start.Z && speed > zTravelSpeed {
+		speed = zTravelSpeed
+	}
+	return generateLinearMove(start, end, width, speed)
+}
+
+// generateLinearMove moves along a straight line to end, all axes move simultaneously
+func generateLinearMove(start, end Point, width float64, speed int) []string {
 	// create move
 	move := make([]string, 0, 1)
 
@@ -681,16 +2820,26 @@ func generateMove(start, end Point, width float64, speed int) []string {
 
 	// add Z
 	if end.Z != start.Z {
-		command += fmt.Sprintf(" Z%s", fmt.Sprint(roundFloat(end.Z, 2)))
+		command += fmt.Sprintf(" Z%s", fmt.Sprint(roundFloat(end.Z+bakedZOffset(), 2)))
 	}
 
 	// add E
-	if width > 0 && math.Sqrt(float64(math.Pow((end.X-start.X), 2)+math.Pow((end.Y-start.Y), 2))) > 0.8 {
-		newE := currentE + calcExtrusion(start, end, width)
-		command += fmt.Sprintf(" E%s", fmt.Sprint(roundFloat(newE, 4)))
+	if width > 0 && (end.X != start.X || end.Y != start.Y) {
+		extrusion := calcExtrusion(start, end, width)
+		extrudedVolume += calcVolume(start, end, width) * float64(flow) / 100
+		newE := currentE + extrusion
+		if relativeE {
+			command += fmt.Sprintf(" E%s", fmt.Sprint(roundFloat(extrusion, 4)))
+		} else {
+			command += fmt.Sprintf(" E%s", fmt.Sprint(roundFloat(newE, 4)))
+		}
 		currentE = newE
 	}
 
+	if width == 0 {
+		travelDistance += math.Sqrt(math.Pow(end.X-start.X, 2) + math.Pow(end.Y-start.Y, 2) + math.Pow(end.Z-start.Z, 2))
+	}
+
 	// add F
 	command += fmt.Sprintf(" F%d", speed*60)
 	currentSpeed = speed
@@ -703,15 +2852,219 @@ func generateMove(start, end Point, width float64, speed int) []string {
 }
 
 func calcExtrusion(start, end Point, width float64) float64 {
-	lineLength := math.Sqrt(float64(math.Pow((end.X-start.X), 2) + math.Pow((end.Y-start.Y), 2)))
-	extrusion := width * layerHeight * lineLength * 4 / math.Pi / math.Pow(filamentDiameter, 2)
+	extrusion := calcVolume(start, end, width)
+	if flowInE {
+		extrusion = extrusion * float64(flow) / 100
+	}
+	if !volumetricE {
+		extrusion = extrusion * 4 / math.Pi / math.Pow(filamentDiameter, 2)
+	}
 	return extrusion
 }
 
+// calcVolume returns volume of plastic in the line in cubic millimeters
+func calcVolume(start, end Point, width float64) float64 {
+	lineLength := math.Sqrt(float64(math.Pow((end.X-start.X), 2) + math.Pow((end.Y-start.Y), 2)))
+	return crossSection(width) * lineLength
+}
+
+// crossSection returns area of line cross-section in square millimeters
+func crossSection(width float64) float64 {
+	if extrusionModel == extrusionRounded && width > layerHeight {
+		// PrusaSlicer model: rectangle with semicircles of layer height diameter on the sides
+		return (width - layerHeight*(1-math.Pi/4)) * layerHeight
+	}
+	return width * layerHeight
+}
+
+// lineFlow returns volumetric flow of line printed with given speed in cubic millimeters per second
+func lineFlow(width float64, speed int) float64 {
+	return crossSection(width) * float64(speed) * float64(flow) / 100
+}
+
+// capSpeed returns the highest speed not greater than speed, that keeps flow of the line within maxFlow
+func capSpeed(width float64, speed int) int {
+	if maxFlow == 0 || lineFlow(width, speed) <= maxFlow {
+		return speed
+	}
+	return int(math.Max(1, math.Floor(maxFlow/lineFlow(width, 1))))
+}
+
+// fastSectionLength returns length of the shortest fast section, it is a half of the right side of the inner perimeter
+func fastSectionLength(width float64) float64 {
+	return (width - lineWidth*2*float64(numPerimeters) - rightShortLine) / 2
+}
+
+// reachedSpeed returns peak speed on a fast section, that starts and ends at slow speed
+func reachedSpeed(length float64) float64 {
+	speed := float64(fastPrintSpeed)
+	if maxVelocity != 0 {
+		speed = math.Min(speed, maxVelocity)
+	}
+	if acceleration == 0 {
+		return speed
+	}
+	// half of the section is used for acceleration and another half for deceleration
+	return math.Min(speed, math.Sqrt(float64(slowPrintSpeed*slowPrintSpeed)+acceleration*math.Max(length, 0)))
+}
+
+// requiredModelWidth returns tower width, that lets fast sections reach fast speed
+func requiredModelWidth() float64 {
+	if acceleration == 0 {
+		return modelWidth
+	}
+	length := float64(fastPrintSpeed*fastPrintSpeed-slowPrintSpeed*slowPrintSpeed) / acceleration
+	return math.Ceil(length*2 + rightShortLine + lineWidth*2*float64(numPerimeters))
+}
+
+// maxModelWidth returns the biggest tower, that fits on the bed with purge lines and neighbour towers
+func maxModelWidth() float64 {
+	if multiTool {
+		return math.Floor(math.Min((bedX-30.0)/float64(numTools)-20.0, bedY/2-25.0))
+	}
+	return math.Floor(math.Min(bedX-40.0, bedY/2-25.0))
+}
+
+// towerSpacing returns distance between centers of towers, grown towers are placed wider
+func towerSpacing() float64 {
+	return math.Max(minTowerSpacing, modelWidth+20.0)
+}
+
+// estimatedLayerTime returns print time of one layer of all towers without accelerations and travels
+func estimatedLayerTime() float64 {
+	slowLength := rightShortLine + frontShortLine + leftShortLine
+	layerTime := 0.0
+	for j := 0; j < numPerimeters; j++ {
+		currentModelWidth := modelWidth - lineWidth*2*float64(j+1)
+		layerTime += (currentModelWidth*4-slowLength)/float64(fastPrintSpeed) + slowLength/float64(slowPrintSpeed)
+	}
+	return layerTime * float64(len(calibratedTools()))
+}
+
+// coolingTowerCenter returns center of the cooling tower, it is also a parking point for dwell
+func coolingTowerCenter(bedCenter Point) Point {
+	center := bedCenter
+	center.Y += (modelWidth+10.0)/2 + 5.0 + coolingTowerSize/2
+	return center
+}
+
+// maxCoolingTowerLoops returns number of loops, that makes the cooling tower solid
+func maxCoolingTowerLoops() int {
+	return int(coolingTowerSize / 2 / lineWidth)
+}
+
+// coolingTowerTime returns print time of given number of cooling tower loops
+func coolingTowerTime(loops int) float64 {
+	time := 0.0
+	for j := 0; j < loops; j++ {
+		time += (coolingTowerSize - lineWidth*float64(2*j+1)) * 4 / float64(slowPrintSpeed)
+	}
+	return time
+}
+
+// coolingTowerLoops returns number of cooling tower loops, that fills the rest of minimum layer time
+func coolingTowerLoops() int {
+	loops := 1
+	for loops < maxCoolingTowerLoops() && coolingTowerTime(loops) < minLayerTime-estimatedLayerTime() {
+		loops++
+	}
+	return loops
+}
+
+// generateCoolingTower prints one layer of the cooling tower from the outer loop to the inner one
+func generateCoolingTower(center Point, speed int) []string {
+	cmds := make([]string, 0)
+	for j := 0; j < coolingTowerLoops(); j++ {
+		side := coolingTowerSize - lineWidth*float64(2*j+1)
+		loopStart := center
+		loopStart.X, loopStart.Y, loopStart.Z = center.X-side/2, center.Y-side/2, currentCoordinates.Z
+		cmds = append(cmds, generateTravel(loopStart)...)
+		cmds = append(cmds, generateRelativeMove(side, 0, 0, lineWidth, speed)...)
+		cmds = append(cmds, generateRelativeMove(0, side, 0, lineWidth, speed)...)
+		cmds = append(cmds, generateRelativeMove(-side, 0, 0, lineWidth, speed)...)
+		cmds = append(cmds, generateRelativeMove(0, -side, 0, lineWidth, speed)...)
+	}
+	towerMin, towerMax := center, center
+	towerMin.X, towerMin.Y = towerMin.X-coolingTowerSize/2, towerMin.Y-coolingTowerSize/2
+	towerMax.X, towerMax.Y = towerMax.X+coolingTowerSize/2, towerMax.Y+coolingTowerSize/2
+	markPrinted(towerMin, towerMax)
+	return cmds
+}
+
+// generateLayerCooling fills the rest of minimum layer time with dwell at parking point or with the cooling tower
+func generateLayerCooling(bedCenter Point) []string {
+	if minLayerTime == 0 {
+		return nil
+	}
+	if layerTimeMode == layerTimeTower {
+		return generateCoolingTower(coolingTowerCenter(bedCenter), slowPrintSpeed)
+	}
+	dwellTime := minLayerTime - estimatedLayerTime()
+	if layerTimeMode != layerTimeDwell || dwellTime <= 0 {
+		return nil
+	}
+
+	// park behind towers, so the nozzle doesn't heat the printed layer. It waits retracted, travel to the next layer deretracts it
+	parkPoint := coolingTowerCenter(bedCenter)
+	parkPoint.Z = currentCoordinates.Z
+	cmds := generateParkTravel(parkPoint)
+	return append(cmds, fmt.Sprintf("G4 P%d\n", int(math.Ceil(dwellTime*1000))))
+}
+
+// generateFlowInfo describes volumetric flows of fast, slow, first layer and purge lines
+func generateFlowInfo() string {
+	return fmt.Sprintf(";Volumetric flow: fast %s, slow %s, first layer %s, purge %s, max %s [mm^3/s]\n",
+		fmt.Sprint(roundFloat(lineFlow(lineWidth, fastPrintSpeed), 1)),
+		fmt.Sprint(roundFloat(lineFlow(lineWidth, slowPrintSpeed), 1)),
+		fmt.Sprint(roundFloat(lineFlow(raftLineWidth(firstLayerLineWidth, modelWidth+10.0), firstLayerPrintSpeed), 1)),
+		fmt.Sprint(roundFloat(lineFlow(firstLayerLineWidth, firstLayerPrintSpeed), 1)),
+		fmt.Sprint(roundFloat(maxFlow, 1)))
+}
+
+// filamentUsage converts volume of plastic to filament length in meters and weight in grams
+func filamentUsage(volume float64) (float64, float64) {
+	return volume * 4 / math.Pi / math.Pow(filamentDiameter, 2) / 1000, volume / 1000 * filamentDensity
+}
+
+// generateFilamentInfo describes filament and its usage in the header
+func generateFilamentInfo() string {
+	name := "custom"
+	if profile, ok := filamentProfiles[filamentType]; ok {
+		name = profile.Name
+	}
+	length, weight := filamentUsage(extrudedVolume)
+	info := fmt.Sprintf(";Filament: %s, diameter %s [mm], density %s [g/cm^3]\n", name, fmt.Sprint(roundFloat(filamentDiameter, 2)), fmt.Sprint(roundFloat(filamentDensity, 2)))
+	info += fmt.Sprintf(";Filament used: %s [m], %s [g]\n", fmt.Sprint(roundFloat(length, 2)), fmt.Sprint(roundFloat(weight, 1)))
+	if filamentPrice > 0 {
+		info += fmt.Sprintf(";Filament cost: %s\n", fmt.Sprint(roundFloat(weight/1000*filamentPrice, 2)))
+	}
+	return info
+}
+
+// generateFilamentUsage describes filament length, weight and cost of extruded volume
+func generateFilamentUsage(lang js.Value, volume float64) string {
+	length, weight := filamentUsage(volume)
+	usage := fmt.Sprintf(lang.Call("getString", "generator.filament_usage").String(), fmt.Sprint(roundFloat(length, 2)), fmt.Sprint(roundFloat(weight, 1)))
+	if filamentPrice > 0 {
+		usage += fmt.Sprintf(lang.Call("getString", "generator.filament_cost").String(), fmt.Sprint(roundFloat(weight/1000*filamentPrice, 2)))
+	}
+	return usage
+}
+
+// raftPointsOnSide returns odd number of zigzag points on a raft side, line width is adjusted to fit them
+func raftPointsOnSide(lineWidth, raftWidth float64) int {
+	pointsOnOneSide := int((raftWidth - lineWidth) / (lineWidth * math.Sqrt(2)))
+	return pointsOnOneSide - (pointsOnOneSide-1)%2
+}
+
+// raftLineWidth returns line width, that generateZigZagTrajectory uses for the raft
+func raftLineWidth(lineWidth, raftWidth float64) float64 {
+	return (raftWidth - lineWidth) / float64(raftPointsOnSide(lineWidth, raftWidth)-1) / math.Sqrt(2)
+}
+
 func generateZigZagTrajectory(towerCenter Point, lineWidth, raftWidth float64) []Point {
 	sideLength := raftWidth - lineWidth
-	pointsOnOneSide := int(sideLength / (lineWidth * math.Sqrt(2)))
-	pointsOnOneSide = pointsOnOneSide - (pointsOnOneSide-1)%2
+	pointsOnOneSide := raftPointsOnSide(lineWidth, raftWidth)
 	pointSpacing := sideLength / float64(pointsOnOneSide-1)
 	firstLayerLineWidth = pointSpacing / math.Sqrt(2)
 
@@ -773,24 +3126,471 @@ func generateRetraction() string {
 	if retracted {
 		fmt.Println("Called retraction, but already retracted")
 		return ""
+	} else if firmwareRetraction {
+		// firmware restores feedrate after retraction
+		retracted = true
+		return "G10\n"
 	} else {
 		retracted = true
 		currentSpeed = retractSpeed
-		return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(currentE-retractLength, 2)), retractSpeed*60)
+		if relativeE {
+			return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(-retractE(), 2)), retractSpeed*60)
+		}
+		return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(currentE-retractE(), 2)), retractSpeed*60)
 	}
 }
 
 func generateDeretraction() string {
-	if retracted {
+	if retracted && firmwareRetraction {
 		retracted = false
-		currentSpeed = retractSpeed
-		return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(currentE, 2)), retractSpeed*60)
+		return "G11\n"
+	} else if retracted {
+		retracted = false
+		currentSpeed = unretractSpeed
+		// extra length primes nozzle after oozing during travel
+		extra := retractExtra
+		if volumetricE {
+			extra = extra * math.Pi * math.Pow(filamentDiameter, 2) / 4
+		}
+		currentE += extra
+		extrudedVolume += retractExtra * math.Pi * math.Pow(filamentDiameter, 2) / 4
+		if relativeE {
+			return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(retractE()+extra, 2)), unretractSpeed*60)
+		}
+		return fmt.Sprintf("G1 E%s F%d\n", fmt.Sprint(roundFloat(currentE, 2)), unretractSpeed*60)
 	} else {
 		fmt.Println("Called deretraction, but not retracted")
 		return ""
 	}
 }
 
+// retractE converts retraction length to E units, in volumetric mode they are cubic millimeters
+func retractE() float64 {
+	if volumetricE {
+		return retractLength * math.Pi * math.Pow(filamentDiameter, 2) / 4
+	}
+	return retractLength
+}
+
+// generateVolumetricSetup turns volumetric extrusion on with filament diameter or turns it off with zero diameter
+func generateVolumetricSetup(teardown bool) []string {
+	if !volumetricE {
+		return nil
+	}
+	diameter := fmt.Sprint(filamentDiameter)
+	if teardown {
+		diameter = "0"
+	}
+	if (firmware == firmwareMarlin || firmware == firmwareRepetier) && (multiTool || tool != 0) {
+		cmds := make([]string, 0, len(calibratedTools()))
+		for _, t := range calibratedTools() {
+			cmds = append(cmds, fmt.Sprintf("M200 T%d D%s\n", t, diameter))
+		}
+		return cmds
+	}
+	// RRF and Smoothieware apply diameter to all extruders
+	return []string{fmt.Sprintf("M200 D%s\n", diameter)}
+}
+
+// generateWipe moves nozzle back along the last printed line in direction (dx, dy) while retracting.
+// It is switched off with firmware retraction, that can't be combined with movement.
+func generateWipe(dx, dy float64) []string {
+	end := currentCoordinates
+	end.X += dx * wipeDistance
+	end.Y += dy * wipeDistance
+	if retracted {
+		return generateMove(currentCoordinates, end, 0.0, travelSpeed)
+	}
+
+	command := "G1"
+	if end.X != currentCoordinates.X {
+		command += fmt.Sprintf(" X%s", fmt.Sprint(roundFloat(end.X, 2)))
+	}
+	if end.Y != currentCoordinates.Y {
+		command += fmt.Sprintf(" Y%s", fmt.Sprint(roundFloat(end.Y, 2)))
+	}
+	if relativeE {
+		command += fmt.Sprintf(" E%s", fmt.Sprint(roundFloat(-retractE(), 2)))
+	} else {
+		command += fmt.Sprintf(" E%s", fmt.Sprint(roundFloat(currentE-retractE(), 2)))
+	}
+	command += fmt.Sprintf(" F%d\n", travelSpeed*60)
+	currentCoordinates = end
+	currentSpeed = travelSpeed
+	retracted = true
+	return []string{command}
+}
+
+// generateTravel moves nozzle to the start of the next extrusion. Long travels are retracted and lifted by Z-hop,
+// nozzle is always deretracted at the end. Travel planner routes around printed areas and lifts only when it can't.
+func generateTravel(end Point) []string {
+	return generateTravelMove(end, true)
+}
+
+// generateParkTravel moves nozzle to the parking point and leaves it retracted, so it doesn't ooze while waiting
+func generateParkTravel(end Point) []string {
+	cmds := generateTravelMove(end, false)
+	if !retracted {
+		cmds = append(cmds, generateRetraction())
+	}
+	return cmds
+}
+
+// generateTravelMove is a travel with optional deretraction at the end
+func generateTravelMove(end Point, deretract bool) []string {
+	cmds := make([]string, 0, 8)
+	path, needHop := []Point{end}, false
+	if avoidCrossing {
+		// planner lifts nozzle only when it can't go around printed walls
+		path, needHop = planTravel(currentCoordinates, end)
+	}
+	distance := pathLength(currentCoordinates, path)
+	if !avoidCrossing {
+		// Z-hop goes with retraction, so short travels aren't lifted
+		needHop = zHop > 0 && distance >= minTravel && distance > 0
+	}
+	if !needHop && (distance < minTravel || distance == 0) {
+		for _, p := range path {
+			cmds = append(cmds, generateMove(currentCoordinates, p, 0.0, travelSpeed)...)
+		}
+		if retracted && deretract {
+			cmds = append(cmds, generateDeretraction())
+		}
+		return cmds
+	}
+
+	if !retracted {
+		cmds = append(cmds, generateRetraction())
+	}
+	if needHop && !(zHop > 0 && firmwareRetraction && retractionSetup && firmware != firmwareKlipper) {
+		// lift above the highest of start and end points, firmware retraction lifts by itself when it is set up with Z-hop
+		hop := zHop
+		if hop == 0 {
+			// planner can't avoid printed walls, but user doesn't want Z-hop, so lift just above them
+			hop = layerHeight
+		}
+		lifted := currentCoordinates
+		lifted.Z = math.Max(currentCoordinates.Z, end.Z) + hop
+		if zHopMode == zHopRamp {
+			// rise during the first half of travel
+			lifted.X, lifted.Y = (currentCoordinates.X+end.X)/2, (currentCoordinates.Y+end.Y)/2
+			cmds = append(cmds, generateLinearMove(currentCoordinates, lifted, 0.0, travelSpeed)...)
+		} else if zHopMode == zHopSpiral {
+			// full circle around current point while rising, it doesn't leave a mark on the top surface
+			cmds = append(cmds, fmt.Sprintf("G2 Z%s I%s J0 F%d\n", fmt.Sprint(roundFloat(lifted.Z+bakedZOffset(), 2)), fmt.Sprint(roundFloat(lineWidth, 2)), zTravelSpeed*60))
+			currentCoordinates.Z = lifted.Z
+			currentSpeed = zTravelSpeed
+		} else {
+			cmds = append(cmds, generateMove(currentCoordinates, lifted, 0.0, travelSpeed)...)
+		}
+		// lifted nozzle goes straight
+		above := end
+		above.Z = lifted.Z
+		path = []Point{above, end}
+	}
+	for _, p := range path {
+		cmds = append(cmds, generateMove(currentCoordinates, p, 0.0, travelSpeed)...)
+	}
+	if deretract {
+		cmds = append(cmds, generateDeretraction())
+	}
+	return cmds
+}
+
+// planTravel returns waypoints from start to end, that don't cross printed areas of the top layer.
+// If there is no such path, straight path is returned and nozzle has to be lifted.
+func planTravel(start, end Point) ([]Point, bool) {
+	straight := []Point{end}
+	blocking := make([]Rect, 0)
+	for _, area := range printedAreas {
+		if area.Z >= end.Z-layerHeight*1.5 && crossesArea(start, end, area, true, true) {
+			blocking = append(blocking, area)
+		}
+	}
+	if len(blocking) == 0 {
+		return straight, false
+	}
+
+	// try to go around blocking areas along one of their sides
+	bounds := blocking[0]
+	for _, area := range blocking[1:] {
+		bounds.MinX, bounds.MinY = math.Min(bounds.MinX, area.MinX), math.Min(bounds.MinY, area.MinY)
+		bounds.MaxX, bounds.MaxY = math.Max(bounds.MaxX, area.MaxX), math.Max(bounds.MaxY, area.MaxY)
+	}
+	margin := lineWidth * 2
+	candidates := make([][]Point, 0, 4)
+	for _, side := range []float64{bounds.MaxY + margin, bounds.MinY - margin} {
+		a, b := start, end
+		a.Y, b.Y = side, side
+		a.Z, b.Z = end.Z, end.Z
+		candidates = append(candidates, []Point{a, b, end})
+	}
+	for _, side := range []float64{bounds.MaxX + margin, bounds.MinX - margin} {
+		a, b := start, end
+		a.X, b.X = side, side
+		a.Z, b.Z = end.Z, end.Z
+		candidates = append(candidates, []Point{a, b, end})
+	}
+
+	var best []Point
+	for _, path := range candidates {
+		if !pathInsideBed(path) || !pathIsFree(start, path, end.Z) {
+			continue
+		}
+		if best == nil || pathLength(start, path) < pathLength(start, best) {
+			best = path
+		}
+	}
+	if best == nil {
+		return straight, true
+	}
+	return best, false
+}
+
+// pathIsFree checks, that path doesn't cross printed areas. Areas with path ends are ignored at first and last legs.
+func pathIsFree(start Point, path []Point, z float64) bool {
+	prev := start
+	for i, p := range path {
+		for _, area := range printedAreas {
+			if area.Z >= z-layerHeight*1.5 && crossesArea(prev, p, area, i == 0, i == len(path)-1) {
+				return false
+			}
+		}
+		prev = p
+	}
+	return true
+}
+
+func pathInsideBed(path []Point) bool {
+	for _, p := range path {
+		if delta && math.Sqrt(p.X*p.X+p.Y*p.Y) > bedX/2 {
+			return false
+		} else if !delta && (p.X < 0 || p.X > bedX || p.Y < 0 || p.Y > bedY) {
+			return false
+		}
+	}
+	return true
+}
+
+func pathLength(start Point, path []Point) float64 {
+	length := 0.0
+	prev := start
+	for _, p := range path {
+		length += math.Sqrt(math.Pow(p.X-prev.X, 2) + math.Pow(p.Y-prev.Y, 2))
+		prev = p
+	}
+	return length
+}
+
+// crossesArea checks if segment from a to b goes through the area. Area containing a or b may be skipped,
+// because nozzle has to leave the wall it has just printed and reach the wall it is going to print.
+func crossesArea(a, b Point, area Rect, skipStart, skipEnd bool) bool {
+	if (skipStart && area.contains(a)) || (skipEnd && area.contains(b)) {
+		return false
+	}
+	// Liang-Barsky clipping of the segment by the rectangle
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.X-a.X, b.Y-a.Y
+	p := []float64{-dx, dx, -dy, dy}
+	q := []float64{a.X - area.MinX, area.MaxX - a.X, a.Y - area.MinY, area.MaxY - a.Y}
+	for i := range p {
+		if p[i] == 0 {
+			if q[i] <= 0 {
+				return false
+			}
+			continue
+		}
+		t := q[i] / p[i]
+		if p[i] < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+	}
+	return t1-t0 > 1e-9
+}
+
+// markPrinted remembers printed area, Z of the same area is updated on every layer
+func markPrinted(min, max Point) {
+	for i := range printedAreas {
+		if printedAreas[i].MinX == min.X && printedAreas[i].MinY == min.Y && printedAreas[i].MaxX == max.X && printedAreas[i].MaxY == max.Y {
+			printedAreas[i].Z = currentCoordinates.Z
+			return
+		}
+	}
+	printedAreas = append(printedAreas, Rect{min.X, min.Y, max.X, max.Y, currentCoordinates.Z})
+}
+
+// generateEReset sets extruder position to zero. Retracted filament is remembered, so deretraction returns to zero.
+func generateEReset() []string {
+	if relativeE {
+		// relative E values don't grow
+		return nil
+	}
+	currentE = 0
+	if retracted && !firmwareRetraction {
+		currentE = retractE()
+	}
+	return []string{"G92 E0\n"}
+}
+
+// generateExtrusionMode states extrusion mode explicitly, so start gcode and macros can't change it unnoticed
+func generateExtrusionMode(relative bool) string {
+	if relative {
+		return "M83\n"
+	}
+	return "M82\n"
+}
+
+// generateRetractionSetup makes firmware retraction use the same length and speed as the rest of calibration
+func generateRetractionSetup() []string {
+	if !firmwareRetraction || !retractionSetup {
+		return nil
+	}
+	length, extra, hop := fmt.Sprint(roundFloat(retractLength, 2)), fmt.Sprint(roundFloat(retractExtra, 2)), fmt.Sprint(roundFloat(zHop, 2))
+	if firmware == firmwareKlipper {
+		// Klipper firmware retraction has no Z-hop
+		return []string{fmt.Sprintf("SET_RETRACTION RETRACT_LENGTH=%s RETRACT_SPEED=%d UNRETRACT_EXTRA_LENGTH=%s UNRETRACT_SPEED=%d\n", length, retractSpeed, extra, unretractSpeed)}
+	} else if firmware == firmwareRRF {
+		return []string{fmt.Sprintf("M207 S%s R%s F%d T%d Z%s\n", length, extra, retractSpeed*60, unretractSpeed*60, hop)}
+	} else if firmware == firmwareRepetier {
+		// Repetier takes length in X and speed in mm/s
+		return []string{fmt.Sprintf("M207 X%s F%d Z%s\n", length, retractSpeed, hop), fmt.Sprintf("M208 X%s F%d\n", extra, unretractSpeed)}
+	}
+	// Marlin and Smoothieware set unretraction separately
+	return []string{fmt.Sprintf("M207 S%s F%d Z%s\n", length, retractSpeed*60, hop), fmt.Sprintf("M208 S%s F%d\n", extra, unretractSpeed*60)}
+}
+
+// parseInputToIntList parses list of integers separated by commas, semicolons or spaces, empty string gives empty list
+func parseInputToIntList(val string) ([]int, error) {
+	list := make([]int, 0)
+	for _, item := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		v, err := parseInputToInt(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// parseInputToFloatList parses list of numbers separated by commas, so decimal separator in the list is only a dot
+func parseInputToFloatList(val string) ([]float64, error) {
+	list := make([]float64, 0)
+	for _, item := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		v, err := parseInputToFloat(item)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func parseKSchedule(val string) int {
+	if val == "log" {
+		return kScheduleLog
+	} else if val == "list" {
+		return kScheduleList
+	}
+
+	return kScheduleLinear
+}
+
+func parseZHopMode(val string) int {
+	if val == "ramp" {
+		return zHopRamp
+	} else if val == "spiral" {
+		return zHopSpiral
+	}
+
+	return zHopNormal
+}
+
+func parseLayerTimeMode(val string) int {
+	if val == "dwell" {
+		return layerTimeDwell
+	} else if val == "tower" {
+		return layerTimeTower
+	}
+
+	return layerTimeSlow
+}
+
+func parseEResetMode(val string) int {
+	if val == "layer" {
+		return eResetLayer
+	} else if val == "segment" {
+		return eResetSegment
+	}
+
+	return eResetNone
+}
+
+func parseExtrusionModel(val string) int {
+	if val == "rounded" {
+		return extrusionRounded
+	}
+
+	return extrusionRectangle
+}
+
+func parseExtrusionAfter(val string) int {
+	if val == "absolute" {
+		return extrusionAfterAbsolute
+	} else if val == "relative" {
+		return extrusionAfterRelative
+	}
+
+	return extrusionAfterNone
+}
+
+func parseProbeMode(val string) int {
+	if val == "full" {
+		return probeFull
+	} else if val == "area" {
+		return probeArea
+	} else if val == "prusa" {
+		return probePrusa
+	} else if val == "load" {
+		return probeLoad
+	}
+
+	return probeNone
+}
+
+func parseZOffsetMode(val string) int {
+	if val == "home_offset" {
+		return zOffsetHomeOffset
+	} else if val == "bake" {
+		return zOffsetBake
+	} else if val == "g92" {
+		return zOffsetG92
+	}
+
+	return zOffsetFirmware
+}
+
+func parseRestoreMode(val string) int {
+	if val == "saved" {
+		return restoreSaved
+	} else if val == "normal" {
+		return restoreNormal
+	}
+
+	return restoreNone
+}
+
+func parseMarlinLAVersion(val string) int {
+	if val == "1.0" {
+		return marlinLA10
+	} else if val == "1.5slot" {
+		return marlinLA15Slot
+	}
+
+	return marlinLA15
+}
+
 func roundFloat(val float64, precision uint) float64 {
 	ratio := math.Pow(10, float64(precision))
 	return math.Round(val*ratio) / ratio
@@ -804,7 +3604,21 @@ func parseInputToFloat(val string) (float64, error) {
 	return f, err
 }
 
+// parseOptionalInputToFloat parses value of field, that can be left empty. Empty field gives zero.
+func parseOptionalInputToFloat(val string) (float64, error) {
+	if strings.TrimSpace(val) == "" {
+		return 0, nil
+	}
+	return parseInputToFloat(val)
+}
+
 func parseInputToInt(val string) (int, error) {
 	f, err := parseInputToFloat(val)
 	return int(roundFloat(f, 0)), err
 }
+
+// parseOptionalInputToInt parses value of field, that can be left empty. Empty field gives zero.
+func parseOptionalInputToInt(val string) (int, error) {
+	f, err := parseOptionalInputToFloat(val)
+	return int(roundFloat(f, 0)), err
+}
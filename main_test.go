@@ -0,0 +1,333 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// keep restores package globals changed by a test, so results don't depend on the test order
+func keep[T any](t *testing.T, vars ...*T) {
+	saved := make([]T, len(vars))
+	for n, v := range vars {
+		saved[n] = *v
+	}
+	t.Cleanup(func() {
+		for n, v := range vars {
+			*v = saved[n]
+		}
+	})
+}
+
+// negative Z-offset lowers the nozzle, restoring must return exactly the previous offset
+func TestZOffsetCommandNegative(t *testing.T) {
+	keep(t, &firmware, &zOffsetMode)
+	keep(t, &zOffset)
+	zOffset = -0.1
+
+	tests := []struct {
+		firmware, mode int
+		start, restore string
+	}{
+		{firmwareMarlin, zOffsetFirmware, "M290 Z-0.1\n", "M290 Z0.1\n"},
+		{firmwareKlipper, zOffsetFirmware, "SET_GCODE_OFFSET Z_ADJUST=-0.1\n", "SET_GCODE_OFFSET Z_ADJUST=0.1\n"},
+		{firmwareRRF, zOffsetFirmware, "M290 S-0.1\n", "M290 S0.1\n"},
+		{firmwareMarlin, zOffsetHomeOffset, "M290 Z-0.1\n", "M290 Z0.1\n"},
+		{firmwareKlipper, zOffsetHomeOffset, "SAVE_GCODE_STATE NAME=K3D_LA_Z_OFFSET\nSET_GCODE_OFFSET Z_ADJUST=-0.1\n", "RESTORE_GCODE_STATE NAME=K3D_LA_Z_OFFSET\n"},
+		{firmwareRRF, zOffsetHomeOffset, "if !exists(global.k3dLaZOffset)\n  global k3dLaZOffset = move.axes[2].workplaceOffsets[0]\nelse\n  set global.k3dLaZOffset = move.axes[2].workplaceOffsets[0]\nG10 L2 P1 Z{global.k3dLaZOffset - 0.1}\n", "G10 L2 P1 Z{global.k3dLaZOffset}\n"},
+	}
+	for _, tt := range tests {
+		firmware, zOffsetMode = tt.firmware, tt.mode
+		if got := generateZOffsetCommand(false); got != tt.start {
+			t.Errorf("firmware %d, mode %d: start %q, want %q", tt.firmware, tt.mode, got, tt.start)
+		}
+		if got := generateZOffsetCommand(true); got != tt.restore {
+			t.Errorf("firmware %d, mode %d: restore %q, want %q", tt.firmware, tt.mode, got, tt.restore)
+		}
+	}
+}
+
+// Marlin slot mode prints the test with the second K slot, restoring must switch back to the first one
+func TestPARestoreMarlinSlot(t *testing.T) {
+	keep(t, &firmware, &marlinLAVersion, &restoreMode, &tool, &numTools)
+	keep(t, &multiTool)
+	firmware, marlinLAVersion, multiTool, tool = firmwareMarlin, marlinLA15Slot, false, 0
+
+	for _, mode := range []int{restoreSaved, restoreNormal} {
+		restoreMode = mode
+		cmds := generatePARestore()
+		if len(cmds) == 0 || cmds[0] != "M900 S0\n" {
+			t.Errorf("restore mode %d: got %q, want M900 S0 first", mode, cmds)
+		}
+		for _, cmd := range cmds {
+			if strings.HasPrefix(cmd, "M900") && strings.Contains(cmd, "S1") {
+				t.Errorf("restore mode %d: second slot is written on restore: %q", mode, cmd)
+			}
+		}
+	}
+
+	multiTool, numTools = true, 2
+	want := []string{"M900 T0 S0\n", "M900 T1 S0\n"}
+	if got := generateLASlotRestore(); strings.Join(got, "") != strings.Join(want, "") {
+		t.Errorf("multi tool: got %q, want %q", got, want)
+	}
+}
+
+// tiny max flow must not stop the printer by capping speed to zero
+func TestCapSpeedMinimum(t *testing.T) {
+	keep(t, &maxFlow, &layerHeight)
+	keep(t, &flow, &extrusionModel)
+	maxFlow, layerHeight, flow = 0.01, 0.2, 100
+
+	if got := capSpeed(0.5, 100); got != 1 {
+		t.Errorf("capSpeed = %d, want 1", got)
+	}
+}
+
+// Klipper reports pressure advance of the active extruder, smooth time must not be taken as K
+func TestParsePrinterInfoKlipperPA(t *testing.T) {
+	info := parsePrinterInfo("// FIRMWARE_NAME:Klipper FIRMWARE_VERSION:v0.12.0\nok\n// pressure_advance: 0.045000\n// pressure_advance_smooth_time: 0.040000\nok\n")
+	if info.Firmware != firmwareKlipper || !info.HasKFactor || info.KFactor != 0.045 {
+		t.Errorf("got firmware %d, K %v (%v), want Klipper K 0.045", info.Firmware, info.KFactor, info.HasKFactor)
+	}
+}
+
+// flow reset must reach every calibrated extruder in the dialect of the firmware
+func TestFlowReset(t *testing.T) {
+	keep(t, &firmware, &numTools, &tool, &currentTool)
+	keep(t, &multiTool)
+	numTools, currentTool = 2, 1
+
+	tests := []struct {
+		firmware  int
+		multiTool bool
+		want      string
+	}{
+		{firmwareMarlin, false, "M221 S100\n"},
+		{firmwareMarlin, true, "M221 T0 S100\nM221 T1 S100\n"},
+		{firmwareKlipper, true, "M221 S100\n"},
+		{firmwareRRF, true, "M221 D0 S100\nM221 D1 S100\n"},
+		{firmwareRepetier, true, "T0\nM221 S100\nT1\nM221 S100\nT1\n"},
+		{firmwareSmoothieware, true, "T0\nM221 S100\nT1\nM221 S100\nT1\n"},
+		{firmwareSmoothieware, false, "M221 S100\n"},
+	}
+	for _, tt := range tests {
+		firmware, multiTool = tt.firmware, tt.multiTool
+		if got := strings.Join(generateFlowReset(), ""); got != tt.want {
+			t.Errorf("firmware %d, multi tool %v: got %q, want %q", tt.firmware, tt.multiTool, got, tt.want)
+		}
+	}
+}
+
+// every calibrated tool gets its K back in the dialect of the firmware. Repetier and Smoothieware change PA
+// of the active extruder only, so each tool is selected for its K.
+func TestPARestoreMultiTool(t *testing.T) {
+	keep(t, &firmware, &restoreMode, &numTools, &tool, &currentTool, &marlinLAVersion)
+	keep(t, &multiTool, &continuousK)
+	keep(t, &normalKFactor, &smoothTime)
+	normalKFactor, smoothTime, numTools, currentTool, marlinLAVersion, continuousK = 0.05, 0.04, 2, 1, marlinLA15, false
+
+	tests := []struct {
+		firmware, restoreMode int
+		multiTool             bool
+		want                  string
+	}{
+		{firmwareSmoothieware, restoreNormal, true, "T0\nM572 S0.05\nT1\nM572 S0.05\nT1\n"},
+		{firmwareRepetier, restoreNormal, true, "T0\nM233 X0 Y0.05\nT1\nM233 X0 Y0.05\nT1\n"},
+		{firmwareSmoothieware, restoreNormal, false, "M572 S0.05\n"},
+		{firmwareMarlin, restoreNormal, true, "M900 T0 K0.05\nM900 T1 K0.05\n"},
+		{firmwareMarlin, restoreSaved, false, "M900 K0.05\n"},
+		{firmwareKlipper, restoreNormal, true, "SET_PRESSURE_ADVANCE EXTRUDER=extruder ADVANCE=0.05 SMOOTH_TIME=0.04\nSET_PRESSURE_ADVANCE EXTRUDER=extruder1 ADVANCE=0.05 SMOOTH_TIME=0.04\n"},
+		{firmwareKlipper, restoreSaved, true, "K3D_LA_RESTORE_PA\nRESTORE_GCODE_STATE NAME=K3D_LA\n"},
+		{firmwareRRF, restoreNormal, true, "M572 D0 S0.05\nM572 D1 S0.05\n"},
+		{firmwareRRF, restoreSaved, true, "M572 D0 S{global.k3dLaPa0}\nM572 D1 S{global.k3dLaPa1}\n"},
+	}
+	for _, tt := range tests {
+		firmware, restoreMode, multiTool, tool = tt.firmware, tt.restoreMode, tt.multiTool, 0
+		// flow reset follows pressure advance, it is checked by TestFlowReset
+		got := strings.Join(generatePARestore(), "")
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("firmware %d, restore mode %d, multi tool %v: got %q, want %q first", tt.firmware, tt.restoreMode, tt.multiTool, got, tt.want)
+		}
+	}
+}
+
+// E steps come from Marlin M503 and Repetier EEPROM, Repetier advance L is scaled by them
+func TestParsePrinterInfoESteps(t *testing.T) {
+	keep(t, &firmware)
+	keep(t, &printerESteps)
+
+	answers := []string{
+		"FIRMWARE_NAME:Marlin 2.1.2\nok\necho:; Steps per unit:\necho:  M92 X80.00 Y80.00 Z400.00 E415.00\nok\n",
+		"FIRMWARE_NAME:Repetier_1.0.4\nok\nEPR:3 207 415.0000 Extr.1 steps per mm\nok\n",
+	}
+	for _, answer := range answers {
+		if info := parsePrinterInfo(answer); !info.HasESteps || info.ESteps != 415 {
+			t.Errorf("%q: got E steps %v (%v), want 415", answer, info.ESteps, info.HasESteps)
+		}
+	}
+
+	firmware, printerESteps = firmwareRepetier, 415
+	if _, endK := defaultKRange(); endK != 83 {
+		t.Errorf("Repetier end K = %v, want 83", endK)
+	}
+}
+
+// travel planner goes around printed walls and lifts nozzle only when there is no way around
+func TestTravelHop(t *testing.T) {
+	keep(t, &bedX, &bedY, &layerHeight, &lineWidth, &zHop, &minTravel, &currentE, &travelDistance)
+	keep(t, &firmware, &zHopMode, &travelSpeed, &zTravelSpeed, &currentSpeed)
+	keep(t, &delta, &avoidCrossing, &firmwareRetraction, &retractionSetup, &retracted, &relativeE)
+	keep(t, &printedAreas)
+	keep(t, &currentCoordinates)
+	bedX, bedY, layerHeight, lineWidth, minTravel, travelSpeed, zTravelSpeed = 100, 100, 0.2, 0.4, 1, 100, 10
+	firmware, zHopMode, delta, avoidCrossing, relativeE = firmwareMarlin, zHopNormal, false, true, false
+
+	tests := []struct {
+		name               string
+		wall               Rect
+		zHop               float64
+		firmwareRetraction bool
+		lifted             bool
+	}{
+		{"route around", Rect{30, 40, 70, 60, 0.2}, 0, false, false},
+		{"no route", Rect{0, 40, 100, 60, 0.2}, 0, false, true},
+		{"no route, firmware retraction without Z-hop", Rect{0, 40, 100, 60, 0.2}, 0, true, true},
+		{"no route, firmware retraction lifts", Rect{0, 40, 100, 60, 0.2}, 0.5, true, false},
+	}
+	for _, tt := range tests {
+		printedAreas = []Rect{tt.wall}
+		zHop, firmwareRetraction, retractionSetup, retracted = tt.zHop, tt.firmwareRetraction, tt.firmwareRetraction, false
+		currentCoordinates = Point{50, 20, 0.2}
+		cmds := strings.Join(generateTravel(Point{50, 80, 0.2}), "")
+		if lifted := strings.Contains(cmds, " Z"); lifted != tt.lifted {
+			t.Errorf("%s: lifted %v, want %v:\n%s", tt.name, lifted, tt.lifted, cmds)
+		}
+		if currentCoordinates != (Point{50, 80, 0.2}) {
+			t.Errorf("%s: travel ends at %v", tt.name, currentCoordinates)
+		}
+	}
+}
+
+// grown towers of several tools are placed wider, so they still don't touch each other
+func TestGrowTowerMultiTool(t *testing.T) {
+	keep(t, &bedX, &bedY, &modelWidth)
+	keep(t, &numTools)
+	keep(t, &multiTool)
+	bedX, bedY, multiTool, numTools = 300, 300, true, 2
+
+	if got := maxModelWidth(); got != 115 {
+		t.Errorf("maxModelWidth = %v, want 115", got)
+	}
+	modelWidth = 40
+	if got := towerSpacing(); got != minTowerSpacing {
+		t.Errorf("default towerSpacing = %v, want %v", got, minTowerSpacing)
+	}
+	modelWidth = 82
+	if got := towerSpacing(); got-(modelWidth+10.0) < 10 {
+		t.Errorf("towerSpacing = %v leaves less than 10 mm between rafts of %v mm towers", got, modelWidth)
+	}
+}
+
+// single tool is heated by start gcode to the hotend temperature, per-tool temperatures must not change its tower
+func TestToolTowerTemperatureSingleTool(t *testing.T) {
+	keep(t, &hotendTemperature, &towerHotendTemperature)
+	keep(t, &toolTemperatures)
+	keep(t, &multiTool)
+	hotendTemperature, towerHotendTemperature, toolTemperatures = 210, 220, []int{240, 250}
+
+	multiTool = false
+	if got := toolTowerTemperature(1); got != 220 {
+		t.Errorf("single tool: tower temperature %d, want 220", got)
+	}
+	multiTool = true
+	if got := toolTowerTemperature(1); got != 260 {
+		t.Errorf("multi tool: tower temperature %d, want 260", got)
+	}
+}
+
+// K schedules go from initial K at the bottom to end K at the top, logarithmic steps grow with K
+func TestScheduleKFactors(t *testing.T) {
+	keep(t, &initKFactor, &endKFactor)
+	keep(t, &numSegments, &kSchedule)
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	numSegments = 5
+
+	initKFactor, endKFactor, kSchedule = 0, 0.1, kScheduleLinear
+	for s, k := range scheduleKFactors() {
+		if want := 0.025 * float64(s); !near(k, want) {
+			t.Errorf("linear segment %d: K %v, want %v", s, k, want)
+		}
+	}
+
+	initKFactor, endKFactor = 0.1, 0
+	for s, k := range scheduleKFactors() {
+		if want := 0.1 - 0.025*float64(s); !near(k, want) {
+			t.Errorf("descending segment %d: K %v, want %v", s, k, want)
+		}
+	}
+
+	initKFactor, endKFactor, kSchedule = 0, 0.1, kScheduleLog
+	factors := scheduleKFactors()
+	if !near(factors[0], 0) || !near(factors[numSegments-1], 0.1) {
+		t.Errorf("logarithmic: goes from %v to %v, want from 0 to 0.1", factors[0], factors[numSegments-1])
+	}
+	for s := 2; s < numSegments; s++ {
+		if factors[s]-factors[s-1] <= factors[s-1]-factors[s-2] {
+			t.Errorf("logarithmic: step %d (%v) isn't bigger than the previous one", s, factors)
+		}
+	}
+}
+
+// continuous K goes linearly through K of segments from the first tower layer to the last one
+func TestContinuousKFactor(t *testing.T) {
+	keep(t, &segmentHeight, &layerHeight)
+	keep(t, &numSegments)
+	keep(t, &kFactors)
+	segmentHeight, layerHeight, numSegments, kFactors = 1, 0.2, 3, []float64{0, 0.1, 0.3}
+
+	tests := []struct {
+		layer, want float64
+	}{
+		{0, 0},
+		{1, 0},
+		{4.25, 0.05},
+		{7.5, 0.1},
+		{14, 0.3},
+		{20, 0.3},
+	}
+	for _, tt := range tests {
+		if got := continuousKFactor(tt.layer); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("layer %v: K %v, want %v", tt.layer, got, tt.want)
+		}
+	}
+}
+
+// LA command addresses the calibrated tool in the dialect of the firmware, default tool keeps the old short form
+func TestLACommandTools(t *testing.T) {
+	keep(t, &firmware, &marlinLAVersion)
+	keep(t, &multiTool, &continuousK)
+	keep(t, &smoothTime)
+	smoothTime, continuousK, multiTool = 0.04, false, false
+
+	tests := []struct {
+		firmware, laVersion, tool int
+		want                      string
+	}{
+		{firmwareMarlin, marlinLA15, 0, "M900 K0.05\n"},
+		{firmwareMarlin, marlinLA15, 1, "M900 T1 K0.05\n"},
+		{firmwareMarlin, marlinLA15Slot, 1, "M900 T1 S1 L0.05\n"},
+		{firmwareKlipper, marlinLA15, 0, "SET_PRESSURE_ADVANCE ADVANCE=0.05 SMOOTH_TIME=0.04\n"},
+		{firmwareKlipper, marlinLA15, 1, "SET_PRESSURE_ADVANCE EXTRUDER=extruder1 ADVANCE=0.05 SMOOTH_TIME=0.04\n"},
+		{firmwareRRF, marlinLA15, 1, "M572 D1 S0.05\n"},
+		{firmwareRepetier, marlinLA15, 1, "M233 X0 Y0.05\n"},
+		{firmwareSmoothieware, marlinLA15, 1, "M572 S0.05\n"},
+	}
+	for _, tt := range tests {
+		firmware, marlinLAVersion = tt.firmware, tt.laVersion
+		if got := generateLACommand(tt.tool, 0.05); got != tt.want {
+			t.Errorf("firmware %d, tool %d: got %q, want %q", tt.firmware, tt.tool, got, tt.want)
+		}
+	}
+}